@@ -0,0 +1,162 @@
+package jsonapi
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// An API holds the configuration shared by the handlers created from it.
+//
+// Two APIs in the same binary, like a public and an admin one, can use different error
+// handlers or error formats without touching the package-level variables. The zero value
+// is ready to use: nil fields fall back to the package-level defaults.
+type API struct {
+	HandleError      ErrorHandlerFunc                        // The error handler. When nil, HandleError is used
+	SendResponse     ResponseSenderFunc                      // The response sender. When nil, SendResponse is used
+	VarFunc          func(r *http.Request) map[string]string // The source of route vars. When nil, VarFunc is used
+	ArgumentResolver ArgumentResolver                        // The argument resolver. When nil, Defaults is used
+	RequestValidator RequestValidator                        // The request validator. When nil, Defaults is used
+	RequestIDHeader  string                                  // The header of the request ID. See WithRequestID
+	Timeout          time.Duration                           // The time the functions have to return. See WithTimeout
+	ClientTimeout    bool                                    // Whether to honor the Request-Timeout header. See WithClientTimeout
+	ServerTiming     bool                                    // Whether to send the Server-Timing header. See WithServerTiming
+	ErrorFormat      ErrorFormat                             // The format of the errors. When zero, DefaultErrorFormat is used
+	ErrorMappings    *ErrorMapper                            // The error mappings. When nil, ErrorMappings is used
+	PanicReporter    PanicReporter                           // The reporter of the panics recovered by the handlers
+	Debug            bool                                    // Whether to include debug info in error responses. See WithDebug
+	Logger           *slog.Logger                            // The logger of the requests. See WithLogger
+	LogDomainErrors  bool                                    // Whether to log the error chain of client errors. When false, the one of Defaults is used
+	LogLevels        *LogLevels                              // The levels of the logged requests. When nil, DefaultLogLevels are used
+	Metrics          *Metrics                                // The collector of the metrics. See WithMetrics
+	Tracer           Tracer                                  // The tracer of the requests. When nil, NoopTracer is used
+	CacheStore       CacheStore                              // The store of the cached responses. When nil, DefaultCache is used
+	CursorKey        []byte                                  // The key the cursors of the pages are signed with. When nil, CursorKey is used
+	Catalog          *MessageCatalog                         // The translations of the messages. When nil, Catalog is used
+
+	// NewErrorReference generates the references of internal errors. When nil, NewErrorReference is used
+	NewErrorReference func() string
+	// LogInternalError logs the errors that end in a 5xx response. When nil, LogInternalError is used
+	LogInternalError func(req *http.Request, ref string, err error)
+}
+
+// defaultAPI is the API used by Wrap and the package-level handlers
+var defaultAPI = &API{}
+
+// Wrap makes a JsonHandler using the configuration of the API. It panics if a filter struct
+// the function takes is not valid, see ParseFilter.
+//
+// See JsonHandler for documentation on how this handler works.
+func (a *API) Wrap(fn interface{}, opts ...OptsFn) *JsonHandler {
+	h := &JsonHandler{
+		fn:               reflectFunc(fn),
+		api:              a,
+		RequestValidator: a.RequestValidator,
+		ArgumentResolver: a.ArgumentResolver,
+	}
+
+	if h.RequestValidator == nil {
+		h.RequestValidator = Defaults
+	}
+
+	if h.ArgumentResolver == nil {
+		h.ArgumentResolver = Defaults
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	checkFilters(h.fn)
+
+	return h
+}
+
+// NotFoundHandler replies with a 404 error, using the configuration of the API
+func (a *API) NotFoundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req = withAPI(req, a)
+		req = withRequestID(w, req, a.RequestIDHeader)
+		a.handleError(w, req, notFoundError(req))
+	})
+}
+
+// MethodNotAllowedHandler replies with a 405 error, using the configuration of the API
+func (a *API) MethodNotAllowedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req = withAPI(req, a)
+		req = withRequestID(w, req, a.RequestIDHeader)
+		a.handleError(w, req, methodNotAllowedError(req))
+	})
+}
+
+func (a *API) handleError(w http.ResponseWriter, req *http.Request, err error) {
+	if a.HandleError != nil {
+		a.HandleError(w, req, err)
+		return
+	}
+
+	HandleError(w, req, err)
+}
+
+func (a *API) sendResponse(w http.ResponseWriter, req *http.Request, v interface{}) {
+	if a.SendResponse != nil {
+		a.SendResponse(w, req, v)
+		return
+	}
+
+	SendResponse(w, req, v)
+}
+
+func (a *API) vars(req *http.Request) map[string]string {
+	if a.VarFunc != nil {
+		return a.VarFunc(req)
+	}
+
+	return VarFunc(req)
+}
+
+func (a *API) errorFormat() ErrorFormat {
+	if a.ErrorFormat != 0 {
+		return a.ErrorFormat
+	}
+
+	return DefaultErrorFormat
+}
+
+func (a *API) logDomainErrors() bool {
+	return a.LogDomainErrors || Defaults.LogDomainErrors
+}
+
+func (a *API) errorMappings() *ErrorMapper {
+	if a.ErrorMappings != nil {
+		return a.ErrorMappings
+	}
+
+	return ErrorMappings
+}
+
+func (a *API) catalog() *MessageCatalog {
+	if a.Catalog != nil {
+		return a.Catalog
+	}
+
+	return Catalog
+}
+
+func (a *API) newErrorReference() string {
+	if a.NewErrorReference != nil {
+		return a.NewErrorReference()
+	}
+
+	return NewErrorReference()
+}
+
+func (a *API) logInternalError(req *http.Request, ref string, err error) {
+	if a.LogInternalError != nil {
+		a.LogInternalError(req, ref, err)
+		return
+	}
+
+	LogInternalError(req, ref, err)
+}
@@ -0,0 +1,118 @@
+package jsonapi_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mnavarrocarter/jsonapi"
+)
+
+func TestAPI(t *testing.T) {
+	public := &jsonapi.API{
+		VarFunc: func(r *http.Request) map[string]string {
+			return map[string]string{"id": "public"}
+		},
+	}
+
+	admin := &jsonapi.API{
+		ErrorFormat: jsonapi.ProblemErrors,
+		VarFunc: func(r *http.Request) map[string]string {
+			return map[string]string{"id": "admin"}
+		},
+	}
+
+	plain := &jsonapi.API{
+		SendResponse: func(w http.ResponseWriter, req *http.Request, v interface{}) {
+			w.WriteHeader(http.StatusTeapot)
+			_, _ = fmt.Fprintf(w, "%v", v)
+		},
+	}
+
+	greet := func(_ context.Context, id string) map[string]string {
+		return map[string]string{"msg": fmt.Sprintf("hello %s", id)}
+	}
+
+	tt := []struct {
+		name             string
+		handler          http.Handler
+		req              *http.Request
+		expectedResponse []byte
+		expectedStatus   int
+	}{
+		{
+			name:             "public var",
+			handler:          public.Wrap(greet, jsonapi.WithVar("id", 1)),
+			req:              httptest.NewRequest(http.MethodGet, "/greet", http.NoBody),
+			expectedResponse: []byte(`{"msg":"hello public"}` + "\n"),
+			expectedStatus:   http.StatusOK,
+		},
+		{
+			name:             "admin var",
+			handler:          admin.Wrap(greet, jsonapi.WithVar("id", 1)),
+			req:              httptest.NewRequest(http.MethodGet, "/greet", http.NoBody),
+			expectedResponse: []byte(`{"msg":"hello admin"}` + "\n"),
+			expectedStatus:   http.StatusOK,
+		},
+		{
+			name:             "public error",
+			handler:          public.Wrap(func(cmd *testCmd) {}),
+			req:              httptest.NewRequest(http.MethodPost, "/plans", http.NoBody),
+			expectedResponse: []byte(`{"status":400,"kind":"Invalid Request","code":"empty_body","details":"Request body cannot be empty"}` + "\n"),
+			expectedStatus:   http.StatusBadRequest,
+		},
+		{
+			name:             "admin error",
+			handler:          admin.Wrap(func(cmd *testCmd) {}),
+			req:              httptest.NewRequest(http.MethodPost, "/plans", http.NoBody),
+			expectedResponse: []byte(`{"type":"about:blank","title":"Bad Request","status":400,"detail":"Request body cannot be empty","instance":"/plans","code":"empty_body","kind":"Invalid Request"}` + "\n"),
+			expectedStatus:   http.StatusBadRequest,
+		},
+		{
+			name:             "admin not found",
+			handler:          admin.NotFoundHandler(),
+			req:              httptest.NewRequest(http.MethodGet, "/users", http.NoBody),
+			expectedResponse: []byte(`{"type":"about:blank","title":"Not Found","status":404,"detail":"No handler found for GET /users","instance":"/users","code":"not_found","kind":"Not Found"}` + "\n"),
+			expectedStatus:   http.StatusNotFound,
+		},
+		{
+			name:             "custom response sender for errors",
+			handler:          plain.Wrap(func(cmd *testCmd) {}),
+			req:              httptest.NewRequest(http.MethodPost, "/plans", http.NoBody),
+			expectedResponse: []byte(`Request body cannot be empty`),
+			expectedStatus:   http.StatusTeapot,
+		},
+		{
+			name:             "custom response sender for method not allowed",
+			handler:          plain.MethodNotAllowedHandler(),
+			req:              httptest.NewRequest(http.MethodDelete, "/plans", http.NoBody),
+			expectedResponse: []byte(`Method not allowed for DELETE /plans`),
+			expectedStatus:   http.StatusTeapot,
+		},
+	}
+
+	for _, test := range tt {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			test.handler.ServeHTTP(rec, test.req)
+
+			if test.expectedStatus != rec.Code {
+				t.Errorf("expected status %d does not match received %d", test.expectedStatus, rec.Code)
+			}
+
+			if !bytes.Equal(test.expectedResponse, rec.Body.Bytes()) {
+				t.Errorf(
+					"response body does not match\nexpected: %s\nreceived: %s\n",
+					string(test.expectedResponse),
+					rec.Body.String(),
+				)
+			}
+		})
+	}
+}
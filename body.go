@@ -0,0 +1,85 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+)
+
+// requestBody wraps the body of a request and caches its contents, so the different
+// stages of the pipeline (validators, resolvers) read and decode it only once.
+type requestBody struct {
+	io.ReadCloser
+	reader  *bytes.Reader
+	raw     []byte
+	readErr error
+	doc     interface{}
+	decoded bool
+	docErr  error
+}
+
+func (b *requestBody) Read(p []byte) (int, error) {
+	if b.reader != nil {
+		return b.reader.Read(p)
+	}
+
+	return b.ReadCloser.Read(p)
+}
+
+// withBody wraps the body of the request so it is cached the first time it is read
+func withBody(req *http.Request) *requestBody {
+	if rb, ok := req.Body.(*requestBody); ok {
+		return rb
+	}
+
+	rb := &requestBody{ReadCloser: req.Body}
+	req.Body = rb
+
+	return rb
+}
+
+// readBody reads the whole body of the request and rewinds it, so it can be consumed again.
+func readBody(req *http.Request) ([]byte, error) {
+	rb := withBody(req)
+
+	if rb.reader == nil {
+		rb.raw, rb.readErr = io.ReadAll(rb.ReadCloser)
+	}
+
+	rb.reader = bytes.NewReader(rb.raw)
+
+	return rb.raw, rb.readErr
+}
+
+// decodeBody decodes the body of the request into a generic json document.
+//
+// Numbers are decoded as json.Number, so no precision is lost. io.EOF is returned
+// when the body is empty.
+//
+// The document is only for validators. Resolvers decode the body again into the argument
+// of the function, so the json.Unmarshaler and encoding.TextUnmarshaler of its types, and
+// the other rules of encoding/json, apply as usual.
+func decodeBody(req *http.Request) (interface{}, error) {
+	b, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	rb := withBody(req)
+	if rb.decoded {
+		return rb.doc, rb.docErr
+	}
+
+	start := time.Now()
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	rb.docErr = dec.Decode(&rb.doc)
+	AddTiming(req.Context(), "decode", time.Since(start))
+	rb.decoded = true
+
+	return rb.doc, rb.docErr
+}
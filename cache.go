@@ -0,0 +1,324 @@
+package jsonapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is the time responses are cached for, when none is given to WithCache
+const DefaultCacheTTL = time.Minute
+
+// A CacheStore keeps the responses cached by the handlers. See WithCache.
+//
+// Stores treat their failures as misses, as the response can always be computed again.
+type CacheStore interface {
+	// Get returns the response kept for a key, unless it has expired
+	Get(key string) (*CachedResponse, bool)
+	// Set keeps the response for a key for ttl, along with its tags
+	Set(key string, resp *CachedResponse, ttl time.Duration, tags []string)
+	// Invalidate forgets the responses with any of the tags
+	Invalidate(tags ...string)
+}
+
+// A CachedResponse is what a CacheStore keeps of a successful response
+type CachedResponse struct {
+	Header http.Header
+	Body   []byte
+	Stored time.Time // When the response was computed
+}
+
+// WithCache makes the handler cache its successful responses to GET and HEAD requests for
+// ttl, so the function is not called again while they are fresh.
+//
+// Responses are kept by the key of the request, or by ByURLAndCredentials when key is nil,
+// so callers with different credentials do not share them. Keys shared by all callers,
+// like ByURL, are only for public data. Responses that depend on other parts of the
+// request, like a header an argument resolver authenticates with, need a key that
+// includes them.
+//
+// The cache is only checked once the request has been validated and its arguments
+// resolved, so requests they reject are never served from it. When the response is not
+// cached, concurrent requests for the same key wait for the first one and share its
+// response, so the function runs once. Requests with a Cache-Control: no-cache header are
+// never served from the cache.
+//
+// Only the headers set by the handler are cached. Those set before it, like the cookies of
+// a middleware, belong to the request that computed the response.
+//
+// Responses are kept in DefaultCache, unless the handler or its API have a CacheStore. See
+// TagCache and InvalidateCache to forget them before they expire.
+func WithCache(ttl time.Duration, key KeyFunc) OptsFn {
+	if ttl == 0 {
+		ttl = DefaultCacheTTL
+	}
+
+	return func(h *JsonHandler) {
+		h.CacheTTL = ttl
+		h.CacheKey = key
+	}
+}
+
+// WithCacheStore sets the store of the responses cached by the handler. See WithCache.
+func WithCacheStore(store CacheStore) OptsFn {
+	return func(h *JsonHandler) {
+		h.CacheStore = store
+	}
+}
+
+// ByURL identifies requests by their path and query. Every caller gets the same response,
+// so it must only be used for public data.
+func ByURL(req *http.Request) string {
+	return req.URL.RequestURI()
+}
+
+// ByURLAndCredentials identifies requests by their path and query, and the credentials in
+// their Authorization and Cookie headers.
+func ByURLAndCredentials(req *http.Request) string {
+	sum := sha256.New()
+	sum.Write([]byte(strings.Join(req.Header.Values("Authorization"), "\n")))
+	sum.Write([]byte{0})
+	sum.Write([]byte(strings.Join(req.Header.Values("Cookie"), "\n")))
+
+	return req.URL.RequestURI() + " " + hex.EncodeToString(sum.Sum(nil))
+}
+
+// TagCache tags the response of the request the context belongs to, so it can be
+// invalidated with InvalidateCache. It does nothing if the response is not cached.
+//
+//	jsonapi.TagCache(ctx, "plans", "plan:"+id)
+func TagCache(ctx context.Context, tags ...string) {
+	if s, ok := ctx.Value(stateKey{}).(*requestState); ok && s.cache != nil {
+		s.cache.tag(tags...)
+	}
+}
+
+// InvalidateCache forgets the cached responses with any of the tags, from the CacheStore
+// of the handler the context belongs to. Functions that change resources call it, so
+// the handlers reading them do not serve stale responses.
+//
+// Handlers with a different CacheStore need to invalidate it directly.
+func InvalidateCache(ctx context.Context, tags ...string) {
+	if h, ok := ctx.Value(handlerKey{}).(*JsonHandler); ok {
+		h.cacheStore().Invalidate(tags...)
+		return
+	}
+
+	if a, ok := ctx.Value(apiKey{}).(*API); ok && a.CacheStore != nil {
+		a.CacheStore.Invalidate(tags...)
+		return
+	}
+
+	DefaultCache.Invalidate(tags...)
+}
+
+func (h *JsonHandler) cacheStore() CacheStore {
+	if h.CacheStore != nil {
+		return h.CacheStore
+	}
+
+	if h.api.CacheStore != nil {
+		return h.api.CacheStore
+	}
+
+	return DefaultCache
+}
+
+func (h *JsonHandler) cacheKey(req *http.Request) string {
+	key := h.CacheKey
+	if key == nil {
+		key = ByURLAndCredentials
+	}
+
+	// Handlers may share the store, so their keys must not collide
+	return h.name() + " " + key(req)
+}
+
+// cacheState is the response of a request to keep in the cache, and its tags
+type cacheState struct {
+	mu     sync.Mutex
+	tags   []string
+	resp   *CachedResponse
+	preset http.Header // The headers set before the function was called, like cookies of middlewares
+}
+
+func (s *cacheState) tag(tags ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tags = append(s.tags, tags...)
+}
+
+// cached serves a request from the cache, if its response is there.
+//
+// Otherwise, it returns the function that keeps the response once the request has been
+// served. If the request must not be served, because it has been served from the cache,
+// done is true.
+func (h *JsonHandler) cached(w *responseWriter, req *http.Request) (finish func(), done bool) {
+	if h.CacheTTL == 0 || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+		return func() {}, false
+	}
+
+	key := h.cacheKey(req)
+	store := h.cacheStore()
+
+	if !noCache(req) {
+		if resp, ok := store.Get(key); ok {
+			h.sendCached(w, req, resp)
+			return nil, true
+		}
+	}
+
+	flights := h.flights(store)
+
+	f, leader := flights.join(key)
+	if !leader {
+		select {
+		case <-f.done:
+			if f.resp != nil {
+				h.sendCached(w, req, f.resp)
+				return nil, true
+			}
+		case <-req.Context().Done():
+		}
+	}
+
+	// Requests that could not share the response of the first one are served on their own
+	st := &cacheState{preset: w.Header().Clone()}
+	stateFrom(req).cache = st
+
+	return func() {
+		if st.resp != nil {
+			st.mu.Lock()
+			store.Set(key, st.resp, h.CacheTTL, st.tags)
+			st.mu.Unlock()
+		}
+
+		if leader {
+			flights.leave(key, f, st.resp)
+		}
+	}, false
+}
+
+// keepResponse keeps a successful response, to cache it once the request has been served
+func (h *JsonHandler) keepResponse(req *http.Request, header http.Header, body []byte) {
+	st := stateFrom(req).cache
+	if st == nil {
+		return
+	}
+
+	header = header.Clone()
+
+	// Only the headers of the response are kept. Those set before, like the cookies of a
+	// middleware or the ID of the request, belong to the request that computed it.
+	for k, v := range st.preset {
+		if slices.Equal(header[k], v) {
+			header.Del(k)
+		}
+	}
+
+	if name := h.requestIDHeader(); name != "" {
+		header.Del(name)
+	}
+
+	st.resp = &CachedResponse{
+		Header: header,
+		Body:   append([]byte(nil), body...),
+		Stored: time.Now(),
+	}
+}
+
+// sendCached sends a cached response, or a 304 Not Modified response if the client
+// already has it.
+func (h *JsonHandler) sendCached(w *responseWriter, req *http.Request, resp *CachedResponse) {
+	// The headers of this request, like its ID, take precedence
+	for k, v := range resp.Header.Clone() {
+		if _, ok := w.Header()[k]; !ok {
+			w.Header()[k] = v
+		}
+	}
+
+	w.Header().Set("Age", strconv.Itoa(int(time.Since(resp.Stored)/time.Second)))
+
+	bw := &bufferedWriter{ResponseWriter: w, status: http.StatusOK}
+	bw.buf.Write(resp.Body)
+
+	sendBuffered(req, bw)
+}
+
+// noCache tells whether the client asked not to be served from a cache
+func noCache(req *http.Request) bool {
+	for _, v := range req.Header.Values("Cache-Control") {
+		for _, directive := range strings.Split(v, ",") {
+			name, _, _ := strings.Cut(strings.TrimSpace(directive), "=")
+
+			if strings.EqualFold(name, "no-cache") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// flightStore is implemented by the stores of this package, which keep the requests
+// computing a response that is not cached, so the concurrent requests for the same key in
+// the same store can wait for it instead of computing it again
+type flightStore interface {
+	inFlight() *flightGroup
+}
+
+// flights returns the requests in flight of a store. The handler keeps those of the stores
+// that do not keep them, so its own concurrent requests are still coalesced.
+func (h *JsonHandler) flights(store CacheStore) *flightGroup {
+	if s, ok := store.(flightStore); ok {
+		return s.inFlight()
+	}
+
+	return &h.inFlight
+}
+
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done chan struct{}
+	resp *CachedResponse // The response, if it can be shared. Set before done is closed
+}
+
+// join returns the flight of a key, and whether the caller leads it and must leave it
+func (g *flightGroup) join(key string) (*flight, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.flights == nil {
+		g.flights = make(map[string]*flight)
+	}
+
+	if f, ok := g.flights[key]; ok {
+		return f, false
+	}
+
+	f := &flight{done: make(chan struct{})}
+	g.flights[key] = f
+
+	return f, true
+}
+
+// leave ends a flight, sharing its response with the requests waiting for it
+func (g *flightGroup) leave(key string, f *flight, resp *CachedResponse) {
+	g.mu.Lock()
+	delete(g.flights, key)
+	g.mu.Unlock()
+
+	f.resp = resp
+	close(f.done)
+}
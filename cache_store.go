@@ -0,0 +1,134 @@
+package jsonapi
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultCacheSize is the number of responses the default cache keeps
+const DefaultCacheSize = 1000
+
+// DefaultCache is the CacheStore of the handlers and APIs that have none
+var DefaultCache CacheStore = NewLRUCache(DefaultCacheSize)
+
+// LRUCache is a CacheStore that keeps the responses in memory. When it is full, the least
+// recently used response is evicted.
+type LRUCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // Of *lruEntry, the most recently used first
+	keys  map[string]*list.Element
+	tags  map[string]map[string]struct{} // The keys of each tag
+
+	flights flightGroup // The requests computing a response for the store
+}
+
+type lruEntry struct {
+	key     string
+	resp    *CachedResponse
+	tags    []string
+	expires time.Time
+}
+
+// NewLRUCache makes an LRUCache that keeps up to size responses. When size is zero,
+// DefaultCacheSize is used.
+func NewLRUCache(size int) *LRUCache {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+
+	return &LRUCache{
+		size:  size,
+		order: list.New(),
+		keys:  make(map[string]*list.Element),
+		tags:  make(map[string]map[string]struct{}),
+	}
+}
+
+func (c *LRUCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.keys[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(el)
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+
+	return entry.resp, true
+}
+
+func (c *LRUCache) Set(key string, resp *CachedResponse, ttl time.Duration, tags []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.keys[key]; ok {
+		c.remove(el)
+	}
+
+	c.keys[key] = c.order.PushFront(&lruEntry{
+		key:     key,
+		resp:    resp,
+		tags:    tags,
+		expires: time.Now().Add(ttl),
+	})
+
+	for _, tag := range tags {
+		if c.tags[tag] == nil {
+			c.tags[tag] = make(map[string]struct{})
+		}
+
+		c.tags[tag][key] = struct{}{}
+	}
+
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *LRUCache) Invalidate(tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			c.remove(c.keys[key])
+		}
+	}
+}
+
+// Len returns the number of responses in the cache
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+// inFlight returns the requests computing a response for the cache. See flightStore.
+func (c *LRUCache) inFlight() *flightGroup {
+	return &c.flights
+}
+
+// remove removes an entry, and its key from the index of its tags. It must be called
+// with the lock held.
+func (c *LRUCache) remove(el *list.Element) {
+	entry := c.order.Remove(el).(*lruEntry)
+	delete(c.keys, entry.key)
+
+	for _, tag := range entry.tags {
+		delete(c.tags[tag], entry.key)
+
+		if len(c.tags[tag]) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package jsonapi_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mnavarrocarter/jsonapi"
+)
+
+func TestWithCache(t *testing.T) {
+	api := testAPI()
+	api.CacheStore = jsonapi.NewLRUCache(10)
+	calls := 0
+	failing := false
+
+	report := api.Wrap(func(ctx context.Context) (*testResp, error) {
+		calls++
+
+		if failing {
+			return nil, errors.New("connection refused")
+		}
+
+		jsonapi.TagCache(ctx, "plans")
+
+		return &testResp{Msg: fmt.Sprintf("report #%d", calls)}, nil
+	}, jsonapi.WithCache(time.Minute, nil), jsonapi.WithETag(jsonapi.StrongETag))
+
+	update := api.Wrap(func(ctx context.Context) {
+		jsonapi.InvalidateCache(ctx, "plans")
+	})
+
+	send := func(handler http.Handler, method, target string, header map[string]string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, http.NoBody)
+
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+
+		handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	expect := func(t *testing.T, rec *httptest.ResponseRecorder, status int, body string) {
+		t.Helper()
+
+		if status != rec.Code {
+			t.Errorf("expected status %d does not match received %d", status, rec.Code)
+		}
+
+		if body != rec.Body.String() {
+			t.Errorf("response body does not match\nexpected: %s\nreceived: %s\n", body, rec.Body.String())
+		}
+	}
+
+	// The first response is cached, and served to the next requests for the same URL
+	first := send(report, http.MethodGet, "/reports", nil)
+	expect(t, first, http.StatusOK, `{"msg":"report #1"}`+"\n")
+
+	hit := send(report, http.MethodGet, "/reports", nil)
+	expect(t, hit, http.StatusOK, `{"msg":"report #1"}`+"\n")
+
+	if age := hit.Header().Get("Age"); age != "0" {
+		t.Errorf("expected the cached response to have an age, got %q", age)
+	}
+
+	if etag := hit.Header().Get("ETag"); etag == "" || etag != first.Header().Get("ETag") {
+		t.Errorf("expected the cached response to keep its ETag, got %q", etag)
+	}
+
+	// Cached responses are conditional too
+	expect(t, send(report, http.MethodGet, "/reports", map[string]string{"If-None-Match": first.Header().Get("ETag")}), http.StatusNotModified, "")
+
+	// Other URLs, and clients asking for a fresh response, are not served from the cache
+	expect(t, send(report, http.MethodGet, "/reports?year=2024", nil), http.StatusOK, `{"msg":"report #2"}`+"\n")
+	expect(t, send(report, http.MethodGet, "/reports", map[string]string{"Cache-Control": "no-cache"}), http.StatusOK, `{"msg":"report #3"}`+"\n")
+	expect(t, send(report, http.MethodGet, "/reports", nil), http.StatusOK, `{"msg":"report #3"}`+"\n")
+
+	// Only safe methods are cached
+	expect(t, send(report, http.MethodPost, "/reports", nil), http.StatusOK, `{"msg":"report #4"}`+"\n")
+
+	// Invalidated responses are computed again, and errors are not cached
+	expect(t, send(update, http.MethodPut, "/plans/gold", nil), http.StatusNoContent, "")
+
+	failing = true
+	expect(t, send(report, http.MethodGet, "/reports", nil), http.StatusInternalServerError,
+		`{"status":500,"kind":"Internal Error","code":"unexpected_error","details":"An unexpected error has occurred","reference":"f00dcafe"}`+"\n")
+
+	failing = false
+	expect(t, send(report, http.MethodGet, "/reports", nil), http.StatusOK, `{"msg":"report #6"}`+"\n")
+	expect(t, send(report, http.MethodGet, "/reports", nil), http.StatusOK, `{"msg":"report #6"}`+"\n")
+}
+
+func TestWithCache_Coalescing(t *testing.T) {
+	stores := []struct {
+		name  string
+		store jsonapi.CacheStore
+	}{
+		{name: "lru cache", store: jsonapi.NewLRUCache(10)},
+		{name: "custom store", store: &mapCache{responses: make(map[string]*jsonapi.CachedResponse)}},
+	}
+
+	for _, test := range stores {
+		t.Run(test.name, func(t *testing.T) {
+			var calls int32
+			release := make(chan struct{})
+
+			handler := jsonapi.Wrap(func() *testResp {
+				n := atomic.AddInt32(&calls, 1)
+				<-release
+
+				return &testResp{Msg: fmt.Sprintf("report #%d", n)}
+			}, jsonapi.WithCache(time.Minute, nil), jsonapi.WithCacheStore(test.store))
+
+			var wg sync.WaitGroup
+			recs := make([]*httptest.ResponseRecorder, 10)
+
+			for i := range recs {
+				recs[i] = httptest.NewRecorder()
+				wg.Add(1)
+
+				go func(rec *httptest.ResponseRecorder) {
+					defer wg.Done()
+					handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/reports", http.NoBody))
+				}(recs[i])
+			}
+
+			// The requests arriving later are served from the cache
+			time.Sleep(20 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			if calls != 1 {
+				t.Errorf("expected the function to be called once, got %d", calls)
+			}
+
+			for _, rec := range recs {
+				if body := rec.Body.String(); body != `{"msg":"report #1"}`+"\n" {
+					t.Errorf("unexpected body %s", body)
+				}
+			}
+		})
+	}
+}
+
+// mapCache is a CacheStore of responses that never expire
+type mapCache struct {
+	mu        sync.Mutex
+	responses map[string]*jsonapi.CachedResponse
+}
+
+func (c *mapCache) Get(key string) (*jsonapi.CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	resp, ok := c.responses[key]
+
+	return resp, ok
+}
+
+func (c *mapCache) Set(key string, resp *jsonapi.CachedResponse, _ time.Duration, _ []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.responses[key] = resp
+}
+
+func (c *mapCache) Invalidate(...string) {}
+
+func TestWithCache_Headers(t *testing.T) {
+	handler := jsonapi.Wrap(func() *testResp {
+		return &testResp{Msg: "report"}
+	}, jsonapi.WithCache(time.Minute, jsonapi.ByURL), jsonapi.WithCacheStore(jsonapi.NewLRUCache(10)), jsonapi.WithCacheControl("max-age=60"))
+
+	// A middleware sets a cookie on the first request only
+	first := true
+	withSession := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if first {
+			w.Header().Set("Set-Cookie", "session=alice")
+			first = false
+		}
+
+		handler.ServeHTTP(w, req)
+	})
+
+	withSession.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/reports", http.NoBody))
+
+	rec := httptest.NewRecorder()
+	withSession.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/reports", http.NoBody))
+
+	if rec.Header().Get("Age") == "" {
+		t.Fatal("expected the response to be served from the cache")
+	}
+
+	if cookie := rec.Header().Get("Set-Cookie"); cookie != "" {
+		t.Errorf("expected the cookie of the first request not to be cached, got %q", cookie)
+	}
+
+	if cc := rec.Header().Get("Cache-Control"); cc != "max-age=60" {
+		t.Errorf("expected the headers of the response to be cached, got Cache-Control %q", cc)
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	cache := jsonapi.NewLRUCache(2)
+	resp := func(body string) *jsonapi.CachedResponse {
+		return &jsonapi.CachedResponse{Body: []byte(body)}
+	}
+
+	cache.Set("a", resp("a"), time.Minute, []string{"plans"})
+	cache.Set("b", resp("b"), time.Minute, []string{"plans", "plan:b"})
+	cache.Get("a")
+	cache.Set("c", resp("c"), time.Minute, nil)
+
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("expected the least recently used response to be evicted")
+	}
+
+	if r, ok := cache.Get("a"); !ok || string(r.Body) != "a" {
+		t.Errorf("expected the recently used response to be kept")
+	}
+
+	cache.Invalidate("plans")
+
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("expected the tagged response to be invalidated")
+	}
+
+	if cache.Len() != 1 {
+		t.Errorf("expected 1 response in the cache, got %d", cache.Len())
+	}
+
+	cache.Set("d", resp("d"), -time.Second, nil)
+
+	if _, ok := cache.Get("d"); ok {
+		t.Errorf("expected the expired response to be forgotten")
+	}
+}
+
+// caller is the client of a request, resolved from its Authorization header
+type caller string
+
+type callerResolver struct {
+	next jsonapi.ArgumentResolver
+}
+
+func (r callerResolver) Resolve(req *http.Request, t reflect.Type, pos int) (reflect.Value, error) {
+	if t != reflect.TypeOf(caller("")) {
+		return r.next.Resolve(req, t, pos)
+	}
+
+	auth := req.Header.Get("Authorization")
+	if auth == "" {
+		return reflect.Value{}, &jsonapi.ValidationError{Items: []*jsonapi.ErrorItem{{Field: "Authorization", Source: jsonapi.SourceHeader, Code: "required", Params: map[string]interface{}{"property": "Authorization"}}}}
+	}
+
+	return reflect.ValueOf(caller(auth)), nil
+}
+
+func TestWithCache_Callers(t *testing.T) {
+	fn := func(c caller) *testResp {
+		return &testResp{Msg: "secret of " + string(c)}
+	}
+
+	send := func(handler http.Handler, auth string) string {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/secret", http.NoBody)
+
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+
+		handler.ServeHTTP(rec, req)
+
+		return rec.Body.String()
+	}
+
+	rejected := `{"status":400,"kind":"Invalid Request","code":"validation_failed","details":"Validation errors","errors":[{"field":"Authorization","source":"header","code":"required","params":{"property":"Authorization"},"value":null,"msg":"Authorization is required"}]}` + "\n"
+
+	tests := []struct {
+		name     string
+		key      jsonapi.KeyFunc
+		expected []string // The responses to alice, nobody and bob
+	}{
+		{
+			name:     "callers do not share responses",
+			expected: []string{`{"msg":"secret of alice"}` + "\n", rejected, `{"msg":"secret of bob"}` + "\n"},
+		},
+		{
+			name:     "rejected requests are not served from a shared key",
+			key:      jsonapi.ByURL,
+			expected: []string{`{"msg":"secret of alice"}` + "\n", rejected, `{"msg":"secret of alice"}` + "\n"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			api := &jsonapi.API{ArgumentResolver: callerResolver{next: jsonapi.Defaults}}
+			handler := api.Wrap(fn,
+				jsonapi.WithCache(time.Minute, test.key),
+				jsonapi.WithCacheStore(jsonapi.NewLRUCache(10)),
+			)
+
+			for i, auth := range []string{"alice", "", "bob"} {
+				if body := send(handler, auth); body != test.expected[i] {
+					t.Errorf("response body does not match\nexpected: %s\nreceived: %s\n", test.expected[i], body)
+				}
+			}
+		})
+	}
+}
+
+func TestWithCache_Stores(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	slow := (&jsonapi.API{CacheStore: jsonapi.NewLRUCache(10)}).Wrap(func() *testResp {
+		close(started)
+		<-release
+
+		return &testResp{Msg: "slow"}
+	}, jsonapi.WithName("report"), jsonapi.WithCache(time.Minute, jsonapi.ByURL))
+
+	fast := (&jsonapi.API{CacheStore: jsonapi.NewLRUCache(10)}).Wrap(func() *testResp {
+		return &testResp{Msg: "fast"}
+	}, jsonapi.WithName("report"), jsonapi.WithCache(time.Minute, jsonapi.ByURL))
+
+	go slow.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/reports", http.NoBody))
+	defer close(release)
+	<-started
+
+	// The same request to a handler with another store does not wait for the slow one
+	done := make(chan string)
+	go func() {
+		rec := httptest.NewRecorder()
+		fast.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/reports", http.NoBody))
+		done <- rec.Body.String()
+	}()
+
+	select {
+	case body := <-done:
+		if body != `{"msg":"fast"}`+"\n" {
+			t.Errorf("unexpected body %s", body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the request waited for the handler of another store")
+	}
+}
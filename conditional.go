@@ -0,0 +1,192 @@
+package jsonapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETagger is implemented by the values returned by functions that know their own ETag,
+// like a version or a hash of the stored entity.
+//
+// The ETag can be given with its quotes, like `W/"v3"`, or without them, like "v3", in
+// which case it is a strong ETag.
+type ETagger interface {
+	ETag() string
+}
+
+// LastModifieder is implemented by the values returned by functions that know when they
+// were modified last.
+type LastModifieder interface {
+	LastModified() time.Time
+}
+
+// ETagMode is the kind of ETag a handler computes for its responses
+type ETagMode int
+
+const (
+	// StrongETag ETags change with every byte of the response
+	StrongETag ETagMode = iota + 1
+	// WeakETag ETags are marked as weak, for responses that are equivalent but not identical
+	WeakETag
+)
+
+// WithETag makes the handler compute an ETag from the body of its responses to GET and
+// HEAD requests, when the returned value does not implement ETagger.
+//
+// Requests whose If-None-Match header matches the ETag get a 304 Not Modified response,
+// so clients polling the handler do not download the same body again. The value returned
+// by the function still has to be built, only its transfer is saved.
+func WithETag(mode ETagMode) OptsFn {
+	return func(h *JsonHandler) {
+		h.ETag = mode
+	}
+}
+
+// WithCacheControl sets the Cache-Control header of the successful responses to GET and
+// HEAD requests, like "private, max-age=60".
+func WithCacheControl(value string) OptsFn {
+	return func(h *JsonHandler) {
+		h.CacheControl = value
+	}
+}
+
+// conditional tells whether the response for v may be conditional
+func (h *JsonHandler) conditional(req *http.Request, v interface{}) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+
+	if h.ETag != 0 || h.CacheControl != "" {
+		return true
+	}
+
+	_, etagger := v.(ETagger)
+	_, modifieder := v.(LastModifieder)
+
+	return etagger || modifieder
+}
+
+// sendConditional sends the response for v, or a 304 Not Modified response if the client
+// already has it. Successful responses are kept in the cache of the request, if any.
+//
+// The validators are those of src, the value the function returned, which v is the
+// projection of when the request asks for some of its fields.
+func (h *JsonHandler) sendConditional(w *responseWriter, req *http.Request, v, src interface{}) {
+	bw := &bufferedWriter{ResponseWriter: w}
+	h.api.sendResponse(bw, req, v)
+
+	if bw.status == http.StatusOK {
+		h.setValidators(w.Header(), src, bw.buf.Bytes())
+		h.keepResponse(req, w.Header(), bw.buf.Bytes())
+	}
+
+	sendBuffered(req, bw)
+}
+
+// setValidators sets the Cache-Control, ETag and Last-Modified headers of a successful response
+func (h *JsonHandler) setValidators(header http.Header, v interface{}, body []byte) {
+	if h.CacheControl != "" {
+		header.Set("Cache-Control", h.CacheControl)
+	}
+
+	if e, ok := v.(ETagger); ok {
+		header.Set("ETag", quoteETag(e.ETag()))
+	} else if h.ETag != 0 {
+		header.Set("ETag", computeETag(body, h.ETag))
+	}
+
+	if m, ok := v.(LastModifieder); ok && !m.LastModified().IsZero() {
+		header.Set("Last-Modified", m.LastModified().UTC().Format(http.TimeFormat))
+	}
+}
+
+// sendBuffered sends a buffered response, or a 304 Not Modified response if it is
+// successful and the client already has it.
+func sendBuffered(req *http.Request, w *bufferedWriter) {
+	header := w.Header()
+	modified, _ := http.ParseTime(header.Get("Last-Modified"))
+
+	if w.status != http.StatusOK || !notModified(req, header.Get("ETag"), modified) {
+		w.send()
+		return
+	}
+
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+	w.ResponseWriter.WriteHeader(http.StatusNotModified)
+}
+
+// notModified evaluates the If-None-Match and If-Modified-Since preconditions of a GET or
+// HEAD request.
+//
+// If-Modified-Since is ignored when If-None-Match is present, as RFC 9110 mandates.
+func notModified(req *http.Request, etag string, modified time.Time) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		return etag != "" && matchETag(inm, etag, true)
+	}
+
+	ims := req.Header.Get("If-Modified-Since")
+	if ims == "" || modified.IsZero() {
+		return false
+	}
+
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+
+	return !modified.After(t)
+}
+
+// matchETag tells whether an ETag is in a list of ETags, like the value of If-None-Match.
+//
+// The weak comparison ignores the W/ prefixes, the strong one does not match weak ETags.
+func matchETag(list, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" {
+			return true
+		}
+
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+
+			continue
+		}
+
+		if candidate == etag && !strings.HasPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func quoteETag(etag string) string {
+	if strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+
+	return `"` + etag + `"`
+}
+
+func computeETag(body []byte, mode ETagMode) string {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	if mode == WeakETag {
+		return "W/" + etag
+	}
+
+	return etag
+}
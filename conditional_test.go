@@ -0,0 +1,207 @@
+package jsonapi_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mnavarrocarter/jsonapi"
+)
+
+type versionedPlan struct {
+	Name    string    `json:"name"`
+	Version string    `json:"-"`
+	Updated time.Time `json:"-"`
+}
+
+func (p *versionedPlan) ETag() string {
+	return p.Version
+}
+
+func (p *versionedPlan) LastModified() time.Time {
+	return p.Updated
+}
+
+type datedPlan struct {
+	Name    string    `json:"name"`
+	Updated time.Time `json:"-"`
+}
+
+func (p *datedPlan) LastModified() time.Time {
+	return p.Updated
+}
+
+func TestConditional(t *testing.T) {
+	updated := time.Date(2024, 3, 10, 12, 30, 15, 500, time.UTC)
+
+	computed := jsonapi.Wrap(func() *testResp {
+		return &testResp{Msg: "gold"}
+	}, jsonapi.WithETag(jsonapi.StrongETag), jsonapi.WithCacheControl("private, max-age=60"))
+
+	weak := jsonapi.Wrap(func() *testResp {
+		return &testResp{Msg: "gold"}
+	}, jsonapi.WithETag(jsonapi.WeakETag))
+
+	versioned := jsonapi.Wrap(func() *versionedPlan {
+		return &versionedPlan{Name: "gold", Version: "v3", Updated: updated}
+	})
+
+	dated := jsonapi.Wrap(func() *datedPlan {
+		return &datedPlan{Name: "gold", Updated: updated}
+	})
+
+	failing := testAPI().Wrap(func() (*testResp, error) {
+		return nil, errors.New("connection refused")
+	}, jsonapi.WithETag(jsonapi.StrongETag), jsonapi.WithCacheControl("private, max-age=60"))
+
+	const goldETag = `"9ff2c9fe925bec7ce4e15c4d8b250ca9"`
+	const goldBody = `{"msg":"gold"}` + "\n"
+
+	tests := []struct {
+		name         string
+		handler      http.Handler
+		method       string
+		header       map[string]string
+		status       int
+		body         string
+		etag         string
+		lastModified string
+		cacheControl string
+	}{
+		{
+			name:         "computed etag",
+			handler:      computed,
+			method:       http.MethodGet,
+			status:       http.StatusOK,
+			body:         goldBody,
+			etag:         goldETag,
+			cacheControl: "private, max-age=60",
+		},
+		{
+			name:         "computed etag matches",
+			handler:      computed,
+			method:       http.MethodGet,
+			header:       map[string]string{"If-None-Match": `"other", ` + goldETag},
+			status:       http.StatusNotModified,
+			etag:         goldETag,
+			cacheControl: "private, max-age=60",
+		},
+		{
+			name:         "wildcard matches",
+			handler:      computed,
+			method:       http.MethodHead,
+			header:       map[string]string{"If-None-Match": "*"},
+			status:       http.StatusNotModified,
+			etag:         goldETag,
+			cacheControl: "private, max-age=60",
+		},
+		{
+			name:    "weak etag",
+			handler: weak,
+			method:  http.MethodGet,
+			status:  http.StatusOK,
+			body:    goldBody,
+			etag:    "W/" + goldETag,
+		},
+		{
+			name:    "weak comparison",
+			handler: weak,
+			method:  http.MethodGet,
+			header:  map[string]string{"If-None-Match": goldETag},
+			status:  http.StatusNotModified,
+			etag:    "W/" + goldETag,
+		},
+		{
+			name:         "etag of the value",
+			handler:      versioned,
+			method:       http.MethodGet,
+			header:       map[string]string{"If-None-Match": `"v2"`},
+			status:       http.StatusOK,
+			body:         `{"name":"gold"}` + "\n",
+			etag:         `"v3"`,
+			lastModified: "Sun, 10 Mar 2024 12:30:15 GMT",
+		},
+		{
+			name:         "if-none-match takes precedence",
+			handler:      versioned,
+			method:       http.MethodGet,
+			header:       map[string]string{"If-None-Match": `"v2"`, "If-Modified-Since": "Sun, 10 Mar 2024 12:30:15 GMT"},
+			status:       http.StatusOK,
+			body:         `{"name":"gold"}` + "\n",
+			etag:         `"v3"`,
+			lastModified: "Sun, 10 Mar 2024 12:30:15 GMT",
+		},
+		{
+			name:         "not modified since",
+			handler:      dated,
+			method:       http.MethodGet,
+			header:       map[string]string{"If-Modified-Since": "Sun, 10 Mar 2024 12:30:15 GMT"},
+			status:       http.StatusNotModified,
+			lastModified: "Sun, 10 Mar 2024 12:30:15 GMT",
+		},
+		{
+			name:         "modified since",
+			handler:      dated,
+			method:       http.MethodGet,
+			header:       map[string]string{"If-Modified-Since": "Sun, 10 Mar 2024 12:30:14 GMT"},
+			status:       http.StatusOK,
+			body:         `{"name":"gold"}` + "\n",
+			lastModified: "Sun, 10 Mar 2024 12:30:15 GMT",
+		},
+		{
+			name:    "unsafe methods are not conditional",
+			handler: computed,
+			method:  http.MethodPost,
+			header:  map[string]string{"If-None-Match": "*"},
+			status:  http.StatusOK,
+			body:    goldBody,
+		},
+		{
+			name:    "errors are not conditional",
+			handler: failing,
+			method:  http.MethodGet,
+			header:  map[string]string{"If-None-Match": "*"},
+			status:  http.StatusInternalServerError,
+			body:    `{"status":500,"kind":"Internal Error","code":"unexpected_error","details":"An unexpected error has occurred","reference":"f00dcafe"}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(test.method, "/plans/gold", http.NoBody)
+
+			for k, v := range test.header {
+				req.Header.Set(k, v)
+			}
+
+			test.handler.ServeHTTP(rec, req)
+
+			if test.status != rec.Code {
+				t.Errorf("expected status %d does not match received %d", test.status, rec.Code)
+			}
+
+			if test.body != rec.Body.String() {
+				t.Errorf("response body does not match\nexpected: %s\nreceived: %s\n", test.body, rec.Body.String())
+			}
+
+			headers := map[string]string{
+				"ETag":          test.etag,
+				"Last-Modified": test.lastModified,
+				"Cache-Control": test.cacheControl,
+			}
+
+			for k, v := range headers {
+				if h := rec.Header().Get(k); h != v {
+					t.Errorf("expected %s header %q does not match received %q", k, v, h)
+				}
+			}
+
+			if rec.Code == http.StatusNotModified && rec.Header().Get("Content-Type") != "" {
+				t.Errorf("expected no content type in a 304 response")
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package jsonapi
+
+import (
+	"context"
+	"net/http"
+)
+
+type handlerKey struct{}
+type apiKey struct{}
+type originalContextKey struct{}
+type stateKey struct{}
+
+// requestState is what is known about a request while it is being served
+type requestState struct {
+	err       error         // The error the request failed with
+	reference string        // The reference of the internal error, if any
+	cache     *cacheState   // The response to cache, if the handler caches it
+	fields    fieldTree     // The fields of the response the request asks for, if any
+	running   chan struct{} // Closed when the function returns, if it outlived the request. See call
+}
+
+// withHandler stores the handler serving the request, and its API, in the context of the
+// request, so the functions that only receive the request, like SendResponse, can use their settings.
+//
+// The context the request came with is kept too. See originalContext.
+func withHandler(req *http.Request, h *JsonHandler) *http.Request {
+	ctx := context.WithValue(req.Context(), originalContextKey{}, req.Context())
+	ctx = context.WithValue(ctx, handlerKey{}, h)
+	ctx = context.WithValue(ctx, apiKey{}, h.api)
+	ctx = context.WithValue(ctx, stateKey{}, &requestState{})
+
+	return req.WithContext(ctx)
+}
+
+// withAPI stores the API serving the request in its context
+func withAPI(req *http.Request, a *API) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), apiKey{}, a))
+}
+
+// handlerFrom returns the handler serving the request, if any
+func handlerFrom(req *http.Request) *JsonHandler {
+	if req == nil {
+		return nil
+	}
+
+	h, _ := req.Context().Value(handlerKey{}).(*JsonHandler)
+
+	return h
+}
+
+// apiFrom returns the API serving the request, or the default one
+func apiFrom(req *http.Request) *API {
+	if req != nil {
+		if a, ok := req.Context().Value(apiKey{}).(*API); ok {
+			return a
+		}
+	}
+
+	return defaultAPI
+}
+
+// stateFrom returns the state of the request. Requests not served by a handler get a
+// throwaway one.
+func stateFrom(req *http.Request) *requestState {
+	if req != nil {
+		if s, ok := req.Context().Value(stateKey{}).(*requestState); ok {
+			return s
+		}
+	}
+
+	return &requestState{}
+}
+
+// originalContext returns the context the request came with, before the handler derived it.
+func originalContext(ctx context.Context) context.Context {
+	if orig, ok := ctx.Value(originalContextKey{}).(context.Context); ok {
+		return orig
+	}
+
+	return ctx
+}
@@ -1,12 +1,15 @@
 package jsonapi
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"reflect"
+	"time"
 )
 
 var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
@@ -18,13 +21,19 @@ var nilValue = reflect.ValueOf(nil)
 var Defaults = &defaults{}
 
 type defaults struct {
-	LogDomainErrors bool
+	LogDomainErrors bool // Whether to log the error chain of client errors, for the APIs that do not. See WithLogger
 }
 
 func (d *defaults) Resolve(req *http.Request, t reflect.Type, pos int) (reflect.Value, error) {
 	if t.Implements(contextType) {
 		val := reflect.ValueOf(req.Context())
 
+		// The handler derives the context of the request, so concrete context types
+		// can only be given the context the request came with.
+		if !val.Type().AssignableTo(t) {
+			val = reflect.ValueOf(originalContext(req.Context()))
+		}
+
 		if !val.Type().AssignableTo(t) {
 			return val, fmt.Errorf("%w: context of type %v is not assignable to argument in pos #%d (%v)", ErrArgumentResolution, val.Type(), pos, t)
 		}
@@ -32,6 +41,26 @@ func (d *defaults) Resolve(req *http.Request, t reflect.Type, pos int) (reflect.
 		return val, nil
 	}
 
+	if t == requestIDType {
+		return reflect.ValueOf(RequestIDFrom(req.Context())), nil
+	}
+
+	if t == pageRequestType {
+		return resolvePageRequest(req)
+	}
+
+	if t == ifMatchType {
+		return reflect.ValueOf(ifMatch(req)), nil
+	}
+
+	if t == loggerType {
+		return reflect.ValueOf(requestLogger(req)), nil
+	}
+
+	if isFilterStruct(t) {
+		return resolveFilter(req, t, pos)
+	}
+
 	if !isStructWithJson(t) {
 		return nilValue, fmt.Errorf("%w: argument #%d (%v)", ErrArgumentUnsupported, pos, t)
 	}
@@ -43,15 +72,25 @@ func (d *defaults) Resolve(req *http.Request, t reflect.Type, pos int) (reflect.
 		t = t.Elem()
 	}
 
+	b, err := readBody(req)
+	if err != nil {
+		return nilValue, fmt.Errorf("%w: %s", ErrArgumentResolution, err.Error())
+	}
+
 	v := reflect.New(t).Interface()
 
-	err := json.NewDecoder(req.Body).Decode(&v)
+	// The body may have been decoded by the validators already, into a generic document.
+	// It is decoded again into the argument, as converting the document would not honor
+	// the rules of encoding/json, like the unmarshalers of the types.
+	start := time.Now()
+	err = json.NewDecoder(bytes.NewReader(b)).Decode(&v)
+	AddTiming(req.Context(), "decode", time.Since(start))
 	if err == io.EOF {
 		return nilValue, ErrEmptyBody
 	}
 
 	if err != nil {
-		return nilValue, fmt.Errorf("%w: %s", ErrArgumentResolution, err.Error())
+		return nilValue, fmt.Errorf("%w: %w", ErrArgumentResolution, err)
 	}
 
 	if ptr {
@@ -61,6 +100,15 @@ func (d *defaults) Resolve(req *http.Request, t reflect.Type, pos int) (reflect.
 	return reflect.Indirect(reflect.ValueOf(v).Elem()), nil
 }
 
+// isDecodeError tells whether an error is caused by a body that is not valid json, or does
+// not match the types of the argument, which are client errors
+func isDecodeError(err error) bool {
+	var se *json.SyntaxError
+	var te *json.UnmarshalTypeError
+
+	return errors.As(err, &se) || errors.As(err, &te) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
 func (d *defaults) Validate(_ *http.Request) ([]*ErrorItem, error) {
 	return nil, nil
 }
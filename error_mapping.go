@@ -0,0 +1,115 @@
+package jsonapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// An ErrorMapping maps domain errors to a status code, so domain packages do not need
+// to implement Coder to control the responses of their errors.
+//
+// An error matches the mapping if it is, or wraps, Err (see errors.Is) or an error of the
+// type of Target (see errors.As).
+type ErrorMapping struct {
+	Err     error       // A sentinel error, like sql.ErrNoRows
+	Target  interface{} // A value of an error type, like (*NotFoundError)(nil)
+	Status  int         // The status code of the response
+	Code    string      // The error code. It is also used to translate the message
+	Message string      // The public message. When empty, the message of the error is used
+}
+
+func (m ErrorMapping) matches(err error) bool {
+	if m.Err != nil && errors.Is(err, m.Err) {
+		return true
+	}
+
+	if m.Target != nil {
+		target := reflect.New(reflect.TypeOf(m.Target))
+		return errors.As(err, target.Interface())
+	}
+
+	return false
+}
+
+func (m ErrorMapping) check() {
+	if m.Err == nil && m.Target == nil {
+		panic("error mapping must have an Err or a Target")
+	}
+
+	if m.Target != nil && !reflect.TypeOf(m.Target).Implements(errorType) {
+		panic(fmt.Sprintf("error mapping target of type %T does not implement error", m.Target))
+	}
+
+	if http.StatusText(m.Status) == "" {
+		panic(fmt.Sprintf("error mapping has an invalid status code %d", m.Status))
+	}
+}
+
+// An ErrorMapper holds a list of ErrorMappings.
+//
+// Mappings are checked in the order they were added, and the first one that matches wins.
+type ErrorMapper struct {
+	mu       sync.RWMutex
+	mappings []ErrorMapping
+}
+
+// Add adds mappings. It panics if a mapping is invalid.
+func (m *ErrorMapper) Add(mappings ...ErrorMapping) {
+	for _, mapping := range mappings {
+		mapping.check()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.mappings = append(m.mappings, mappings...)
+}
+
+// Lookup returns the first mapping that matches err
+func (m *ErrorMapper) Lookup(err error) (ErrorMapping, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return lookupMapping(m.mappings, err)
+}
+
+// ErrorMappings is the global registry of ErrorMappings, used by every handler.
+var ErrorMappings = &ErrorMapper{}
+
+// WithErrorMapping adds error mappings to the handler.
+//
+// The mappings of the handler are checked before the global ones in ErrorMappings.
+func WithErrorMapping(mappings ...ErrorMapping) OptsFn {
+	for _, mapping := range mappings {
+		mapping.check()
+	}
+
+	return func(h *JsonHandler) {
+		h.ErrorMappings = append(h.ErrorMappings, mappings...)
+	}
+}
+
+// lookupErrorMapping finds the mapping for an error, in the handler of the request first
+// and then in the mappings of its API, which are the global ones by default.
+func lookupErrorMapping(req *http.Request, err error) (ErrorMapping, bool) {
+	if h := handlerFrom(req); h != nil {
+		if m, ok := lookupMapping(h.ErrorMappings, err); ok {
+			return m, true
+		}
+	}
+
+	return apiFrom(req).errorMappings().Lookup(err)
+}
+
+func lookupMapping(mappings []ErrorMapping, err error) (ErrorMapping, bool) {
+	for _, m := range mappings {
+		if m.matches(err) {
+			return m, true
+		}
+	}
+
+	return ErrorMapping{}, false
+}
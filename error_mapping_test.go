@@ -0,0 +1,154 @@
+package jsonapi_test
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mnavarrocarter/jsonapi"
+)
+
+var errConflict = errors.New("plan already exists")
+
+// conflictMapping maps errConflict to a 409 error
+var conflictMapping = jsonapi.ErrorMapping{
+	Err:    errConflict,
+	Status: http.StatusConflict,
+	Code:   "plan_conflict",
+}
+
+type planLockedErr struct {
+	id string
+}
+
+func (e *planLockedErr) Error() string {
+	return fmt.Sprintf("plan %s is locked", e.id)
+}
+
+// withGlobalMapping maps errConflict to a 409 error in the global ErrorMappings, for the
+// duration of a test
+func withGlobalMapping(t *testing.T) {
+	prev := jsonapi.ErrorMappings
+	t.Cleanup(func() {
+		jsonapi.ErrorMappings = prev
+	})
+
+	jsonapi.ErrorMappings = &jsonapi.ErrorMapper{}
+	jsonapi.ErrorMappings.Add(conflictMapping)
+}
+
+func TestWithErrorMapping(t *testing.T) {
+	tt := []struct {
+		name             string
+		handler          http.Handler
+		global           bool
+		expectedResponse []byte
+		expectedStatus   int
+	}{
+		{
+			name: "wrapped sentinel error",
+			handler: jsonapi.Wrap(func() error {
+				return fmt.Errorf("could not find plan: %w", sql.ErrNoRows)
+			}, jsonapi.WithErrorMapping(jsonapi.ErrorMapping{
+				Err:     sql.ErrNoRows,
+				Status:  http.StatusNotFound,
+				Code:    "plan_not_found",
+				Message: "Plan not found",
+			})),
+			expectedResponse: []byte(`{"status":404,"code":"plan_not_found","details":"Plan not found"}` + "\n"),
+			expectedStatus:   http.StatusNotFound,
+		},
+		{
+			name: "typed error",
+			handler: jsonapi.Wrap(func() error {
+				return fmt.Errorf("could not update plan: %w", &planLockedErr{id: "1234"})
+			}, jsonapi.WithErrorMapping(jsonapi.ErrorMapping{
+				Target: (*planLockedErr)(nil),
+				Status: http.StatusLocked,
+			})),
+			expectedResponse: []byte(`{"status":423,"details":"could not update plan: plan 1234 is locked"}` + "\n"),
+			expectedStatus:   http.StatusLocked,
+		},
+		{
+			name: "global mapping",
+			handler: jsonapi.Wrap(func() error {
+				return errConflict
+			}),
+			global:           true,
+			expectedResponse: []byte(`{"status":409,"code":"plan_conflict","details":"plan already exists"}` + "\n"),
+			expectedStatus:   http.StatusConflict,
+		},
+		{
+			name: "handler mapping takes precedence",
+			handler: jsonapi.Wrap(func() error {
+				return errConflict
+			}, jsonapi.WithErrorMapping(jsonapi.ErrorMapping{
+				Err:    errConflict,
+				Status: http.StatusUnprocessableEntity,
+			})),
+			global:           true,
+			expectedResponse: []byte(`{"status":422,"details":"plan already exists"}` + "\n"),
+			expectedStatus:   http.StatusUnprocessableEntity,
+		},
+		{
+			name: "mapping takes precedence over coder",
+			handler: jsonapi.Wrap(func() error {
+				return customErr(http.StatusBadRequest, errConflict, "customer error")
+			}),
+			global:           true,
+			expectedResponse: []byte(`{"status":409,"code":"plan_conflict","details":"customer error"}` + "\n"),
+			expectedStatus:   http.StatusConflict,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			if test.global {
+				withGlobalMapping(t)
+			}
+
+			rec := httptest.NewRecorder()
+
+			test.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/plans/1234", http.NoBody))
+
+			if test.expectedStatus != rec.Code {
+				t.Errorf("expected status %d does not match received %d", test.expectedStatus, rec.Code)
+			}
+
+			if !bytes.Equal(test.expectedResponse, rec.Body.Bytes()) {
+				t.Errorf(
+					"response body does not match\nexpected: %s\nreceived: %s\n",
+					string(test.expectedResponse),
+					rec.Body.String(),
+				)
+			}
+		})
+	}
+}
+
+func TestWithErrorMapping_Invalid(t *testing.T) {
+	tt := []struct {
+		name    string
+		mapping jsonapi.ErrorMapping
+	}{
+		{name: "no error", mapping: jsonapi.ErrorMapping{Status: http.StatusNotFound}},
+		{name: "not an error type", mapping: jsonapi.ErrorMapping{Target: "not found", Status: http.StatusNotFound}},
+		{name: "invalid status", mapping: jsonapi.ErrorMapping{Err: sql.ErrNoRows}},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("invalid mapping should have panicked")
+				}
+			}()
+
+			jsonapi.WithErrorMapping(test.mapping)
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package jsonapi
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 )
 
@@ -15,7 +18,76 @@ var ErrArgumentUnsupported = errors.New("argument resolution unsupported")
 var HandleError ErrorHandlerFunc = handleError
 
 func handleError(w http.ResponseWriter, req *http.Request, err error) {
-	SendResponse(w, req, err)
+	apiFrom(req).sendResponse(w, req, err)
+}
+
+// LogInternalError logs the errors that end in a 5xx response, with the reference
+// sent to the client, so they can be correlated. It is used by the APIs that have no
+// LogInternalError of their own.
+//
+// Handlers with a logger log the reference and the error along with the request, so by
+// default they are not logged twice. See WithLogger.
+var LogInternalError = func(req *http.Request, ref string, err error) {
+	if h := handlerFrom(req); h != nil && h.logger() != nil {
+		return
+	}
+
+	requestLogger(req).LogAttrs(
+		req.Context(),
+		slog.LevelError,
+		"internal error",
+		slog.String("reference", ref),
+		slog.Any("error", errorChain(err)),
+	)
+}
+
+// NewErrorReference generates the references of internal errors, for the APIs that have
+// no NewErrorReference of their own
+var NewErrorReference = func() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// Public marks an error as public, so its message is sent to clients even when it ends
+// in a 5xx response.
+//
+// Errors wrapping a public error are public too, and their whole message is sent, so only
+// wrap them with messages that can be read by clients.
+func Public(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &publicError{err}
+}
+
+// PublicError is implemented by errors whose message can be sent to clients in a 5xx response
+type PublicError interface {
+	Public() bool
+}
+
+type publicError struct {
+	error
+}
+
+func (e *publicError) Unwrap() error {
+	return e.error
+}
+
+func (e *publicError) Public() bool {
+	return true
+}
+
+// Code keeps the status code of the wrapped error, if any
+func (e *publicError) Code() int {
+	var c Coder
+	if errors.As(e.error, &c) {
+		return c.Code()
+	}
+
+	return http.StatusInternalServerError
 }
 
 // Wrapper mimics the unwrap functionality in go errors package
@@ -28,10 +100,48 @@ type Coder interface {
 	Code() int
 }
 
+// ErrorCoder yields a stable, machine-readable code for an error, like "out_of_stock"
+type ErrorCoder interface {
+	ErrorCode() string
+}
+
+// Kinder yields the category of an error, like "Invalid Request"
+type Kinder interface {
+	Kind() string
+}
+
+// Detailer yields extra members to add to the body of an error response
+type Detailer interface {
+	Details() map[string]interface{}
+}
+
+// The kinds of the errors of this package
+const (
+	KindInvalidRequest     = "Invalid Request"
+	KindNotFound           = "Not Found"
+	KindMethodNotAllowed   = "Method Not Allowed"
+	KindInternal           = "Internal Error"
+	KindTimeout            = "Timeout"
+	KindRateLimited        = "Rate Limited"
+	KindConflict           = "Conflict"
+	KindPreconditionFailed = "Precondition Failed"
+)
+
+// ProblemTyper yields the type URI of an error, when rendered as problem details.
+//
+// Errors that do not implement it are rendered with the "about:blank" type.
+type ProblemTyper interface {
+	ProblemType() string
+}
+
 type apiError struct {
-	code int
-	msg  string
-	prev error
+	code    int                    // The http status code
+	msg     string                 // The message, in english
+	errCode string                 // The error code, also used to translate the message
+	kind    string                 // The kind of error
+	params  map[string]interface{} // The parameters of the message
+	stack   []byte                 // The stack trace, for panics
+	prev    error
 }
 
 func (e *apiError) Unwrap() error {
@@ -46,7 +156,20 @@ func (e *apiError) Code() int {
 	return e.code
 }
 
-func panicToError(v interface{}) (err error) {
+func (e *apiError) ErrorCode() string {
+	return e.errCode
+}
+
+func (e *apiError) Kind() string {
+	return e.kind
+}
+
+// Public is true, as the messages of the errors of this package are safe to send
+func (e *apiError) Public() bool {
+	return true
+}
+
+func panicToError(v interface{}, stack []byte) (err error) {
 	switch t := v.(type) {
 	case string:
 		err = errors.New(t)
@@ -57,8 +180,29 @@ func panicToError(v interface{}) (err error) {
 	}
 
 	return &apiError{
-		code: http.StatusInternalServerError,
-		msg:  "An unexpected error has occurred",
-		prev: err,
+		code:    http.StatusInternalServerError,
+		msg:     "An unexpected error has occurred",
+		errCode: "unexpected_error",
+		kind:    KindInternal,
+		stack:   stack,
+		prev:    err,
 	}
 }
+
+// errorChain returns the messages of an error and of all the errors it wraps
+func errorChain(err error) []string {
+	var chain []string
+
+	for err != nil {
+		chain = append(chain, err.Error())
+
+		w, ok := err.(Wrapper)
+		if !ok {
+			break
+		}
+
+		err = w.Unwrap()
+	}
+
+	return chain
+}
@@ -0,0 +1,334 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// WithSparseFields lets clients ask for only some of the fields of the responses of the
+// handler, with the fields parameter of the query string, like
+// "?fields=id,name,address.city". Nested fields are separated by dots.
+//
+// The fields apply to the objects of the response, to each of the objects of a list, and
+// to the items of a Page. Requests for fields the type the function returns does not have,
+// or that are not in allowed, when given, get a 400 error before the function is called.
+// Allowing a field allows its nested fields too.
+func WithSparseFields(allowed ...string) OptsFn {
+	return func(h *JsonHandler) {
+		h.SparseFields = true
+		h.AllowedFields = allowed
+	}
+}
+
+// fieldTree are the fields of an object to keep. A nil subtree keeps the whole field.
+type fieldTree map[string]fieldTree
+
+func (t fieldTree) add(path []string) {
+	node := t
+
+	for i, name := range path {
+		if i == len(path)-1 {
+			node[name] = nil
+			return
+		}
+
+		child, ok := node[name]
+		if ok && child == nil {
+			// The whole field is already kept
+			return
+		}
+
+		if !ok {
+			child = fieldTree{}
+			node[name] = child
+		}
+
+		node = child
+	}
+}
+
+// sparseFields parses the fields a request asks for the response of the function, before
+// it is called. It returns nil if there are none, and the fields that are not allowed, or
+// the type the function returns does not have, as ErrorItems.
+func (h *JsonHandler) sparseFields(req *http.Request) (fieldTree, []*ErrorItem) {
+	param := strings.TrimSpace(req.URL.Query().Get("fields"))
+	if !h.SparseFields || param == "" {
+		return nil, nil
+	}
+
+	t := pageItemsType(h.fn.out)
+
+	tree := fieldTree{}
+
+	var items []*ErrorItem
+
+	for _, field := range strings.Split(param, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		path := strings.Split(field, ".")
+
+		if !h.fieldAllowed(field) || t == nil || !hasField(t, path) {
+			items = append(items, &ErrorItem{
+				Field:   "fields",
+				Pointer: JSONPointer("fields"),
+				Source:  SourceQuery,
+				Code:    "unknown_field",
+				Params:  map[string]interface{}{"path": field},
+				Value:   field,
+				Msg:     "Unknown field " + field,
+			})
+			continue
+		}
+
+		tree.add(path)
+	}
+
+	if len(items) != 0 {
+		return nil, items
+	}
+
+	if len(tree) == 0 {
+		return nil, nil
+	}
+
+	return tree, nil
+}
+
+var pagerType = reflect.TypeOf((*pager)(nil)).Elem()
+
+// pageItemsType returns the type of the items of the pages of type t, the fields apply to,
+// or t when it is not a Page.
+func pageItemsType(t reflect.Type) reflect.Type {
+	if t == nil || !t.Implements(pagerType) {
+		return t
+	}
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if f, ok := t.FieldByName("Items"); ok {
+		return f.Type
+	}
+
+	return t
+}
+
+// fieldAllowed tells whether a field, or one of the fields it is nested in, is allowed
+func (h *JsonHandler) fieldAllowed(field string) bool {
+	if len(h.AllowedFields) == 0 {
+		return true
+	}
+
+	for _, allowed := range h.AllowedFields {
+		if field == allowed || strings.HasPrefix(field, allowed+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// hasField tells whether the json encoding of the values of type t has the field at path.
+// Values whose fields cannot be known from their type, like maps and interfaces, have
+// all of them.
+func hasField(t reflect.Type, path []string) bool {
+	if len(path) == 0 {
+		return true
+	}
+
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Map:
+		return hasField(t.Elem(), path[1:])
+	case reflect.Struct:
+		f, ok := jsonField(t, path[0])
+		return ok && hasField(f.Type, path[1:])
+	default:
+		return false
+	}
+}
+
+// jsonField finds the field of a struct encoded with the given name
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+
+		// The fields of embedded structs are promoted
+		if f.Anonymous && tag == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+
+			if ft.Kind() == reflect.Struct {
+				if ef, ok := jsonField(ft, name); ok {
+					return ef, true
+				}
+
+				continue
+			}
+		}
+
+		if tag == "" {
+			tag = f.Name
+		}
+
+		if tag == name {
+			return f, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+// projectFields encodes v, keeping only the fields in the tree
+func projectFields(v interface{}, tree fieldTree) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	return project(dec, tree)
+}
+
+// project decodes the next value of dec, keeping only the fields in the tree
+func project(dec *json.Decoder, tree fieldTree) (interface{}, error) {
+	if tree == nil {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+
+		return raw, err
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		obj := &jsonObject{}
+
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			sub, ok := tree[key.(string)]
+			if !ok {
+				var skip json.RawMessage
+				if err := dec.Decode(&skip); err != nil {
+					return nil, err
+				}
+
+				continue
+			}
+
+			value, err := project(dec, sub)
+			if err != nil {
+				return nil, err
+			}
+
+			obj.keys = append(obj.keys, key.(string))
+			obj.values = append(obj.values, value)
+		}
+
+		_, err = dec.Token()
+
+		return obj, err
+	case json.Delim('['):
+		list := []interface{}{}
+
+		for dec.More() {
+			value, err := project(dec, tree)
+			if err != nil {
+				return nil, err
+			}
+
+			list = append(list, value)
+		}
+
+		_, err = dec.Token()
+
+		return list, err
+	default:
+		return tok, nil
+	}
+}
+
+// codedValue is a value sent with the status code of another, like the projection of a
+// value that implements Coder
+type codedValue struct {
+	value interface{}
+	code  int
+}
+
+func (v *codedValue) Code() int {
+	return v.code
+}
+
+func (v *codedValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.value)
+}
+
+// jsonObject is a json object that keeps the order of its keys
+type jsonObject struct {
+	keys   []string
+	values []interface{}
+}
+
+func (o *jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,206 @@
+package jsonapi_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mnavarrocarter/jsonapi"
+)
+
+type address struct {
+	Street string `json:"street"`
+	City   string `json:"city"`
+}
+
+type customer struct {
+	ID       int               `json:"id"`
+	Name     string            `json:"name"`
+	Email    string            `json:"email,omitempty"`
+	Address  *address          `json:"address"`
+	Tags     []string          `json:"tags"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+func TestWithSparseFields(t *testing.T) {
+	gold := &customer{
+		ID:       1,
+		Name:     "Gold Corp",
+		Address:  &address{Street: "Main St 1", City: "Santiago"},
+		Tags:     []string{"vip"},
+		Metadata: map[string]string{"tier": "gold", "region": "south"},
+	}
+
+	silver := &customer{ID: 2, Name: "Silver Ltd", Address: &address{Street: "Side St 2", City: "Lima"}}
+
+	one := jsonapi.Wrap(func() *customer {
+		return gold
+	}, jsonapi.WithSparseFields())
+
+	list := jsonapi.Wrap(func() []*customer {
+		return []*customer{gold, silver}
+	}, jsonapi.WithSparseFields("id", "name", "address"))
+
+	page := jsonapi.Wrap(func() jsonapi.Page[*customer] {
+		return jsonapi.Page[*customer]{Items: []*customer{gold, silver}, Total: 2}
+	}, jsonapi.WithSparseFields())
+
+	plain := jsonapi.Wrap(func() *customer {
+		return silver
+	})
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		target  string
+		status  int
+		body    string
+	}{
+		{
+			name:    "nested fields",
+			handler: one,
+			target:  "/customers/1?fields=name,address.city,id",
+			status:  http.StatusOK,
+			body:    `{"id":1,"name":"Gold Corp","address":{"city":"Santiago"}}` + "\n",
+		},
+		{
+			name:    "whole nested objects and maps",
+			handler: one,
+			target:  "/customers/1?fields=address,address.city,metadata.tier,email",
+			status:  http.StatusOK,
+			body:    `{"address":{"street":"Main St 1","city":"Santiago"},"metadata":{"tier":"gold"}}` + "\n",
+		},
+		{
+			name:    "no fields",
+			handler: one,
+			target:  "/customers/1?fields=",
+			status:  http.StatusOK,
+			body:    `{"id":1,"name":"Gold Corp","address":{"street":"Main St 1","city":"Santiago"},"tags":["vip"],"metadata":{"region":"south","tier":"gold"}}` + "\n",
+		},
+		{
+			name:    "unknown fields",
+			handler: one,
+			target:  "/customers/1?fields=id,phone,address.zip,name.first",
+			status:  http.StatusBadRequest,
+			body:    `{"status":400,"kind":"Invalid Request","code":"validation_failed","details":"Validation errors","errors":[{"field":"fields","pointer":"/fields","source":"query","code":"unknown_field","params":{"path":"phone"},"value":"phone","msg":"Unknown field phone"},{"field":"fields","pointer":"/fields","source":"query","code":"unknown_field","params":{"path":"address.zip"},"value":"address.zip","msg":"Unknown field address.zip"},{"field":"fields","pointer":"/fields","source":"query","code":"unknown_field","params":{"path":"name.first"},"value":"name.first","msg":"Unknown field name.first"}]}` + "\n",
+		},
+		{
+			name:    "list",
+			handler: list,
+			target:  "/customers?fields=id,address.city",
+			status:  http.StatusOK,
+			body:    `[{"id":1,"address":{"city":"Santiago"}},{"id":2,"address":{"city":"Lima"}}]` + "\n",
+		},
+		{
+			name:    "field that is not allowed",
+			handler: list,
+			target:  "/customers?fields=id,tags",
+			status:  http.StatusBadRequest,
+			body:    `{"status":400,"kind":"Invalid Request","code":"validation_failed","details":"Validation errors","errors":[{"field":"fields","pointer":"/fields","source":"query","code":"unknown_field","params":{"path":"tags"},"value":"tags","msg":"Unknown field tags"}]}` + "\n",
+		},
+		{
+			name:    "page",
+			handler: page,
+			target:  "/customers?fields=name",
+			status:  http.StatusOK,
+			body:    `{"items":[{"name":"Gold Corp"},{"name":"Silver Ltd"}],"meta":{"limit":20,"offset":0,"total":2}}` + "\n",
+		},
+		{
+			name:    "handler without sparse fields",
+			handler: plain,
+			target:  "/customers/2?fields=id",
+			status:  http.StatusOK,
+			body:    `{"id":2,"name":"Silver Ltd","address":{"street":"Side St 2","city":"Lima"},"tags":null}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			test.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.target, http.NoBody))
+
+			if test.status != rec.Code {
+				t.Errorf("expected status %d does not match received %d", test.status, rec.Code)
+			}
+
+			if test.body != rec.Body.String() {
+				t.Errorf("response body does not match\nexpected: %s\nreceived: %s\n", test.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+// versionedCustomer is a customer that knows its own ETag
+type versionedCustomer struct {
+	customer
+}
+
+func (c *versionedCustomer) ETag() string {
+	return "v3"
+}
+
+func TestWithSparseFields_Function(t *testing.T) {
+	calls := 0
+
+	handler := jsonapi.Wrap(func() *versionedCustomer {
+		calls++
+
+		return &versionedCustomer{customer{ID: 3, Name: "Bronze Inc"}}
+	}, jsonapi.WithSparseFields(), jsonapi.WithIdempotency(jsonapi.NewMemoryIdempotencyStore(time.Minute)))
+
+	// Fields the function does not return are rejected before it is called
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/customers?fields=bogus", http.NoBody)
+	req.Header.Set(jsonapi.IdempotencyKeyHeader, "a1")
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest || calls != 0 {
+		t.Errorf("expected a 400 without calling the function, got %d after %d calls", rec.Code, calls)
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/customers?fields=id", http.NoBody)
+	req.Header.Set(jsonapi.IdempotencyKeyHeader, "a1")
+	handler.ServeHTTP(rec, req)
+
+	if body := `{"id":3}` + "\n"; rec.Code != http.StatusOK || rec.Body.String() != body {
+		t.Errorf("expected the key to be usable after the rejected request, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// The validators are those of the value the function returned
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/customers?fields=name", http.NoBody))
+
+	if etag := rec.Header().Get("ETag"); etag != `"v3"` {
+		t.Errorf("expected the ETag of the customer, got %q", etag)
+	}
+
+	if body := `{"name":"Bronze Inc"}` + "\n"; rec.Body.String() != body {
+		t.Errorf("response body does not match\nexpected: %s\nreceived: %s\n", body, rec.Body.String())
+	}
+}
+
+// createdCustomer is a customer sent with a 201 Created status
+type createdCustomer struct {
+	customer
+}
+
+func (c *createdCustomer) Code() int {
+	return http.StatusCreated
+}
+
+func TestWithSparseFields_Status(t *testing.T) {
+	handler := jsonapi.Wrap(func() *createdCustomer {
+		return &createdCustomer{customer{ID: 4, Name: "Copper SA"}}
+	}, jsonapi.WithSparseFields())
+
+	for _, target := range []string{"/customers", "/customers?fields=id"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, http.NoBody))
+
+		if rec.Code != http.StatusCreated {
+			t.Errorf("%s: expected status %d does not match received %d", target, http.StatusCreated, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,457 @@
+package jsonapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The operators of the filters
+const (
+	OpEq   = "eq"
+	OpNe   = "ne"
+	OpGt   = "gt"
+	OpGte  = "gte"
+	OpLt   = "lt"
+	OpLte  = "lte"
+	OpLike = "like"
+	OpIn   = "in"
+)
+
+var filterOps = []string{OpEq, OpNe, OpGt, OpGte, OpLt, OpLte, OpLike, OpIn}
+
+// ErrFilterDefinition is returned when a filter struct is not valid
+var ErrFilterDefinition = errors.New("invalid filter definition")
+
+// Sort is the order a request asks for, from its sort parameter, like "?sort=-created,name"
+type Sort []SortField
+
+// A SortField is a field to sort by
+type SortField struct {
+	Name string
+	Desc bool
+}
+
+var sortType = reflect.TypeOf(Sort{})
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// ParseFilter binds the filter and sort parameters of a query string into dst, a pointer to
+// a filter struct, like:
+//
+//	?filter[status]=active&filter[created][gte]=2024-01-01&sort=-created,name
+//
+// The fields of the struct declare the filters clients can use, with the name and the
+// operator in the filter tag. The operator is eq when it is omitted. Fields must be pointers,
+// which are nil when the filter is not used, or slices for the in operator, whose values
+// are separated by commas. A Sort field declares the fields the results can be sorted by:
+//
+//	type PlanFilter struct {
+//		Status        *string      `filter:"status"`
+//		Statuses      []string     `filter:"status,in"`
+//		CreatedAfter  *time.Time   `filter:"created,gte" sql:"created_at"`
+//		Sort          jsonapi.Sort `sort:"created,name"`
+//	}
+//
+// Functions can take filter structs as arguments. Unknown filters, operators or sort
+// fields, and values that cannot be parsed, are reported in a *ValidationError, which
+// ends in a 400 response. Wrap panics if a filter struct of the function is not valid.
+func ParseFilter(query url.Values, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: %T is not a pointer to a struct", ErrFilterDefinition, dst)
+	}
+
+	spec, err := filterSpecOf(v.Elem().Type())
+	if err != nil {
+		return err
+	}
+
+	v = v.Elem()
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var items []*ErrorItem
+
+	for _, key := range keys {
+		value := query.Get(key)
+
+		if key == "sort" {
+			items = append(items, spec.bindSort(v, value)...)
+			continue
+		}
+
+		name, op, ok := parseFilterKey(key)
+		if !ok {
+			continue
+		}
+
+		ops, ok := spec.filters[name]
+		if !ok {
+			items = append(items, queryItem(key, value, "unknown_filter", map[string]interface{}{"allowed": strings.Join(spec.names, ", ")}))
+			continue
+		}
+
+		field, ok := ops[op]
+		if !ok {
+			items = append(items, queryItem(key, value, "unknown_operator", map[string]interface{}{"operator": op, "allowed": strings.Join(spec.ops[name], ", ")}))
+			continue
+		}
+
+		if item := bindFilterValue(v.Field(field.index), key, query[key]); item != nil {
+			items = append(items, item)
+		}
+	}
+
+	if len(items) != 0 {
+		return &ValidationError{Items: items}
+	}
+
+	return nil
+}
+
+// isFilterStruct tells whether a type is a filter struct, or a pointer to one
+func isFilterStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if _, ok := f.Tag.Lookup("filter"); ok {
+			return true
+		}
+
+		if _, ok := f.Tag.Lookup("sort"); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkFilters checks the filter structs a function takes, so a wrong definition is found
+// when the handler is made, not when it serves a request. It panics if one is not valid.
+func checkFilters(fn *reflectedFn) {
+	for _, t := range fn.in {
+		if !isFilterStruct(t) {
+			continue
+		}
+
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		if _, err := filterSpecOf(t); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// parseFilterKey parses a filter parameter, like filter[created][gte]
+func parseFilterKey(key string) (name, op string, ok bool) {
+	rest, ok := strings.CutPrefix(key, "filter[")
+	if !ok {
+		return "", "", false
+	}
+
+	name, rest, ok = strings.Cut(rest, "]")
+	if !ok || name == "" {
+		return "", "", false
+	}
+
+	if rest == "" {
+		return name, OpEq, true
+	}
+
+	if !strings.HasPrefix(rest, "[") || !strings.HasSuffix(rest, "]") {
+		return "", "", false
+	}
+
+	return name, rest[1 : len(rest)-1], true
+}
+
+// bindFilterValue parses the values of a filter into its field
+func bindFilterValue(field reflect.Value, key string, values []string) *ErrorItem {
+	t := field.Type()
+
+	if t.Kind() == reflect.Slice {
+		var parts []string
+		for _, v := range values {
+			parts = append(parts, strings.Split(v, ",")...)
+		}
+
+		slice := reflect.MakeSlice(t, 0, len(parts))
+
+		for _, part := range parts {
+			elem := reflect.New(t.Elem()).Elem()
+			if !parseFilterScalar(elem, part) {
+				return queryItem(key, part, "type", map[string]interface{}{"expected": filterTypeName(t.Elem()), "given": "string"})
+			}
+
+			slice = reflect.Append(slice, elem)
+		}
+
+		field.Set(slice)
+
+		return nil
+	}
+
+	elem := reflect.New(t.Elem())
+	if !parseFilterScalar(elem.Elem(), values[0]) {
+		return queryItem(key, values[0], "type", map[string]interface{}{"expected": filterTypeName(t.Elem()), "given": "string"})
+	}
+
+	field.Set(elem)
+
+	return nil
+}
+
+// parseFilterScalar parses a single value into v
+func parseFilterScalar(v reflect.Value, s string) bool {
+	if v.Type() == timeType {
+		for _, layout := range []string{time.RFC3339, time.DateOnly} {
+			if t, err := time.Parse(layout, s); err == nil {
+				v.Set(reflect.ValueOf(t))
+				return true
+			}
+		}
+
+		return false
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return false
+		}
+
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return false
+		}
+
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return false
+		}
+
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return false
+		}
+
+		v.SetFloat(n)
+	default:
+		return false
+	}
+
+	return true
+}
+
+func filterTypeName(t reflect.Type) string {
+	if t == timeType {
+		return "date-time"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+func isFilterScalar(t reflect.Type) bool {
+	if t == timeType {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+// filterSpec is what a filter struct declares
+type filterSpec struct {
+	fields  []filterField                     // The fields of the filters, in order
+	filters map[string]map[string]filterField // The fields of the filters, by name and operator
+	names   []string                          // The names of the filters, in order
+	ops     map[string][]string               // The operators of each filter, in order
+	columns map[string]string                 // The column of each filter
+	sort    int                               // The index of the Sort field, or -1
+	sorts   []string                          // The fields the results can be sorted by
+}
+
+type filterField struct {
+	index  int
+	name   string
+	op     string
+	column string
+}
+
+var filterSpecs sync.Map // Of reflect.Type to *filterSpec
+
+func filterSpecOf(t reflect.Type) (*filterSpec, error) {
+	if spec, ok := filterSpecs.Load(t); ok {
+		return spec.(*filterSpec), nil
+	}
+
+	spec := &filterSpec{
+		filters: make(map[string]map[string]filterField),
+		ops:     make(map[string][]string),
+		columns: make(map[string]string),
+		sort:    -1,
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		_, isSort := f.Tag.Lookup("sort")
+		_, isFilter := f.Tag.Lookup("filter")
+
+		if (isSort || isFilter) && !f.IsExported() {
+			return nil, fmt.Errorf("%w: field %s must be exported", ErrFilterDefinition, f.Name)
+		}
+
+		if tag, ok := f.Tag.Lookup("sort"); ok {
+			if f.Type != sortType {
+				return nil, fmt.Errorf("%w: sort field %s must be a jsonapi.Sort", ErrFilterDefinition, f.Name)
+			}
+
+			spec.sort = i
+			spec.sorts = strings.Split(tag, ",")
+			continue
+		}
+
+		tag, ok := f.Tag.Lookup("filter")
+		if !ok {
+			continue
+		}
+
+		name, op, _ := strings.Cut(tag, ",")
+		if op == "" {
+			op = OpEq
+		}
+
+		switch {
+		case !slices.Contains(filterOps, op):
+			return nil, fmt.Errorf("%w: unknown operator %q in filter field %s", ErrFilterDefinition, op, f.Name)
+		case op == OpIn && (f.Type.Kind() != reflect.Slice || !isFilterScalar(f.Type.Elem())):
+			return nil, fmt.Errorf("%w: filter field %s must be a slice", ErrFilterDefinition, f.Name)
+		case op != OpIn && (f.Type.Kind() != reflect.Ptr || !isFilterScalar(f.Type.Elem())):
+			return nil, fmt.Errorf("%w: filter field %s must be a pointer", ErrFilterDefinition, f.Name)
+		}
+
+		column := f.Tag.Get("sql")
+		if column == "" {
+			column = name
+		}
+
+		if spec.filters[name] == nil {
+			spec.filters[name] = make(map[string]filterField)
+			spec.names = append(spec.names, name)
+			spec.columns[name] = column
+		}
+
+		field := filterField{index: i, name: name, op: op, column: column}
+		spec.fields = append(spec.fields, field)
+		spec.filters[name][op] = field
+		spec.ops[name] = append(spec.ops[name], op)
+	}
+
+	filterSpecs.Store(t, spec)
+
+	return spec, nil
+}
+
+// bindSort parses the sort parameter into the Sort field of v
+func (s *filterSpec) bindSort(v reflect.Value, value string) []*ErrorItem {
+	// The sort parameter is left to others when the struct does not declare it
+	if s.sort < 0 {
+		return nil
+	}
+
+	var fields Sort
+	var items []*ErrorItem
+
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		desc := strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+
+		if !slices.Contains(s.sorts, name) {
+			items = append(items, queryItem("sort", name, "unknown_sort", map[string]interface{}{"allowed": strings.Join(s.sorts, ", ")}))
+			continue
+		}
+
+		fields = append(fields, SortField{Name: name, Desc: desc})
+	}
+
+	v.Field(s.sort).Set(reflect.ValueOf(fields))
+
+	return items
+}
+
+// resolveFilter resolves a filter struct argument of a function
+func resolveFilter(req *http.Request, t reflect.Type, pos int) (reflect.Value, error) {
+	ptr := t.Kind() == reflect.Ptr
+	if ptr {
+		t = t.Elem()
+	}
+
+	v := reflect.New(t)
+
+	err := ParseFilter(req.URL.Query(), v.Interface())
+
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		return nilValue, err
+	}
+
+	if err != nil {
+		return nilValue, fmt.Errorf("%w: argument #%d (%v): %s", ErrArgumentResolution, pos, t, err.Error())
+	}
+
+	if ptr {
+		return v, nil
+	}
+
+	return v.Elem(), nil
+}
@@ -0,0 +1,135 @@
+package jsonapi
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A Placeholder returns the placeholder of the nth argument of a query, counting from 1
+type Placeholder func(n int) string
+
+// QuestionPlaceholder is the placeholder of MySQL and SQLite, like "?"
+func QuestionPlaceholder(int) string {
+	return "?"
+}
+
+// DollarPlaceholder is the placeholder of PostgreSQL, like "$1"
+func DollarPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// SQLClause is a filter struct translated to SQL, for database/sql
+type SQLClause struct {
+	Where   string        // The conditions, like "status = $1 AND created_at >= $2", if any
+	OrderBy string        // The order, like "created_at DESC, name", if any
+	Args    []interface{} // The arguments of the placeholders of Where
+}
+
+// String returns the WHERE and ORDER BY clauses, like
+// "WHERE status = $1 ORDER BY created_at DESC", or an empty string if there are none
+func (c *SQLClause) String() string {
+	var clauses []string
+
+	if c.Where != "" {
+		clauses = append(clauses, "WHERE "+c.Where)
+	}
+
+	if c.OrderBy != "" {
+		clauses = append(clauses, "ORDER BY "+c.OrderBy)
+	}
+
+	return strings.Join(clauses, " ")
+}
+
+// sqlOperators are the SQL operators of the filter operators
+var sqlOperators = map[string]string{
+	OpEq:   "=",
+	OpNe:   "<>",
+	OpGt:   ">",
+	OpGte:  ">=",
+	OpLt:   "<",
+	OpLte:  "<=",
+	OpLike: "LIKE",
+	OpIn:   "IN",
+}
+
+// FilterSQL translates a filter struct bound with ParseFilter into the parameterized
+// conditions and order of a query. When placeholder is nil, QuestionPlaceholder is used.
+//
+//	clause, err := jsonapi.FilterSQL(filter, jsonapi.DollarPlaceholder)
+//	rows, err := db.QueryContext(ctx, "SELECT id, name FROM plans "+clause.String(), clause.Args...)
+//
+// Columns are the ones in the sql tags of the fields, or the names of the filters. Sort
+// fields use the column of the filter with the same name. The values are always passed
+// as arguments, and the columns only come from the struct, so clients cannot inject SQL.
+func FilterSQL(filter interface{}, placeholder Placeholder) (*SQLClause, error) {
+	v := reflect.Indirect(reflect.ValueOf(filter))
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %T is not a struct", ErrFilterDefinition, filter)
+	}
+
+	spec, err := filterSpecOf(v.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	if placeholder == nil {
+		placeholder = QuestionPlaceholder
+	}
+
+	clause := &SQLClause{}
+
+	var conditions []string
+
+	for _, field := range spec.fields {
+		fv := v.Field(field.index)
+		if fv.IsNil() || (fv.Kind() == reflect.Slice && fv.Len() == 0) {
+			continue
+		}
+
+		if field.op != OpIn {
+			clause.Args = append(clause.Args, fv.Elem().Interface())
+			conditions = append(conditions, field.column+" "+sqlOperators[field.op]+" "+placeholder(len(clause.Args)))
+			continue
+		}
+
+		placeholders := make([]string, fv.Len())
+		for i := range placeholders {
+			clause.Args = append(clause.Args, fv.Index(i).Interface())
+			placeholders[i] = placeholder(len(clause.Args))
+		}
+
+		conditions = append(conditions, field.column+" IN ("+strings.Join(placeholders, ", ")+")")
+	}
+
+	clause.Where = strings.Join(conditions, " AND ")
+
+	if spec.sort >= 0 {
+		var order []string
+
+		for _, f := range v.Field(spec.sort).Interface().(Sort) {
+			// Sort values can be set by callers, so only the declared ones are used as columns
+			if !slices.Contains(spec.sorts, f.Name) {
+				return nil, fmt.Errorf("%w: unknown sort field %q", ErrFilterDefinition, f.Name)
+			}
+
+			column, ok := spec.columns[f.Name]
+			if !ok {
+				column = f.Name
+			}
+
+			if f.Desc {
+				column += " DESC"
+			}
+
+			order = append(order, column)
+		}
+
+		clause.OrderBy = strings.Join(order, ", ")
+	}
+
+	return clause, nil
+}
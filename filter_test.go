@@ -0,0 +1,159 @@
+package jsonapi_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mnavarrocarter/jsonapi"
+)
+
+type planFilter struct {
+	Status        *string      `filter:"status"`
+	Statuses      []string     `filter:"status,in"`
+	CreatedAfter  *time.Time   `filter:"created,gte" sql:"created_at"`
+	CreatedBefore *time.Time   `filter:"created,lt" sql:"created_at"`
+	MinPrice      *int         `filter:"price,gte"`
+	Sort          jsonapi.Sort `sort:"created,name"`
+}
+
+func TestParseFilter(t *testing.T) {
+	handler := jsonapi.Wrap(func(f *planFilter) (*testResp, error) {
+		clause, err := jsonapi.FilterSQL(f, jsonapi.DollarPlaceholder)
+		if err != nil {
+			return nil, err
+		}
+
+		args, _ := json.Marshal(clause.Args)
+
+		return &testResp{Msg: clause.String() + " " + string(args)}, nil
+	})
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		body   string
+	}{
+		{
+			name:   "no filter",
+			query:  "",
+			status: http.StatusOK,
+			body:   `{"msg":" null"}` + "\n",
+		},
+		{
+			name:   "filters and sort",
+			query:  "filter[status]=active&filter[created][gte]=2024-01-01&filter[price][gte]=10&sort=-created,name",
+			status: http.StatusOK,
+			body:   `{"msg":"WHERE status = $1 AND created_at \u003e= $2 AND price \u003e= $3 ORDER BY created_at DESC, name [\"active\",\"2024-01-01T00:00:00Z\",10]"}` + "\n",
+		},
+		{
+			name:   "in operator",
+			query:  "filter[status][in]=active,trial&filter[created][lt]=2024-06-01T10:00:00Z",
+			status: http.StatusOK,
+			body:   `{"msg":"WHERE status IN ($1, $2) AND created_at \u003c $3 [\"active\",\"trial\",\"2024-06-01T10:00:00Z\"]"}` + "\n",
+		},
+		{
+			name:   "unknown filter, operator and sort field",
+			query:  "filter[owner]=me&filter[status][like]=act%25&sort=price",
+			status: http.StatusBadRequest,
+			body:   `{"status":400,"kind":"Invalid Request","code":"validation_failed","details":"Validation errors","errors":[{"field":"filter[owner]","pointer":"/filter[owner]","source":"query","code":"unknown_filter","params":{"allowed":"status, created, price"},"value":"me","msg":"Unknown filter, it must be one of: status, created, price"},{"field":"filter[status][like]","pointer":"/filter[status][like]","source":"query","code":"unknown_operator","params":{"allowed":"eq, in","operator":"like"},"value":"act%","msg":"Unknown operator like, it must be one of: eq, in"},{"field":"sort","pointer":"/sort","source":"query","code":"unknown_sort","params":{"allowed":"created, name"},"value":"price","msg":"Unknown sort field, it must be one of: created, name"}]}` + "\n",
+		},
+		{
+			name:   "invalid values",
+			query:  "filter[created][gte]=yesterday&filter[price][gte]=cheap",
+			status: http.StatusBadRequest,
+			body:   `{"status":400,"kind":"Invalid Request","code":"validation_failed","details":"Validation errors","errors":[{"field":"filter[created][gte]","pointer":"/filter[created][gte]","source":"query","code":"type","params":{"expected":"date-time","given":"string"},"value":"yesterday","msg":"Invalid type. Expected: date-time, given: string"},{"field":"filter[price][gte]","pointer":"/filter[price][gte]","source":"query","code":"type","params":{"expected":"integer","given":"string"},"value":"cheap","msg":"Invalid type. Expected: integer, given: string"}]}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/plans?"+test.query, http.NoBody))
+
+			if test.status != rec.Code {
+				t.Errorf("expected status %d does not match received %d", test.status, rec.Code)
+			}
+
+			if test.body != rec.Body.String() {
+				t.Errorf("response body does not match\nexpected: %s\nreceived: %s\n", test.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestFilterSQL(t *testing.T) {
+	query, _ := url.ParseQuery("filter[status][in]=active,trial&filter[price][gte]=10&sort=name")
+
+	var f planFilter
+	if err := jsonapi.ParseFilter(query, &f); err != nil {
+		t.Fatal(err)
+	}
+
+	clause, err := jsonapi.FilterSQL(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &jsonapi.SQLClause{
+		Where:   "status IN (?, ?) AND price >= ?",
+		OrderBy: "name",
+		Args:    []interface{}{"active", "trial", 10},
+	}
+
+	if !reflect.DeepEqual(expected, clause) {
+		t.Errorf("expected clause %+v does not match received %+v", expected, clause)
+	}
+
+	type invalidFilter struct {
+		Status string `filter:"status"`
+	}
+
+	if err := jsonapi.ParseFilter(query, &invalidFilter{}); err == nil {
+		t.Errorf("expected an error for a filter field that is not a pointer")
+	}
+
+	// Sort values set by callers are not used as columns unless the struct declares them
+	f.Sort = jsonapi.Sort{{Name: "name; DROP TABLE plans"}}
+
+	if _, err := jsonapi.FilterSQL(f, nil); !errors.Is(err, jsonapi.ErrFilterDefinition) {
+		t.Errorf("expected an error for an unknown sort field, got %v", err)
+	}
+}
+
+func TestWrap_InvalidFilter(t *testing.T) {
+	type unexportedFilter struct {
+		status *string `filter:"status"`
+	}
+
+	type valueFilter struct {
+		Status string `filter:"status"`
+	}
+
+	tt := []struct {
+		name string
+		fn   interface{}
+	}{
+		{name: "unexported field", fn: func(f unexportedFilter) {}},
+		{name: "field that is not a pointer", fn: func(f *valueFilter) {}},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				err, _ := recover().(error)
+				if !errors.Is(err, jsonapi.ErrFilterDefinition) {
+					t.Errorf("Wrap should have panicked with a filter definition error, got %v", err)
+				}
+			}()
+
+			jsonapi.Wrap(test.fn)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package jsonapi
 import (
 	"fmt"
 	"reflect"
+	"runtime"
 )
 
 var errorType = reflect.TypeOf((*error)(nil)).Elem()
@@ -22,8 +23,9 @@ func reflectFunc(fn interface{}) *reflectedFn {
 	}
 
 	rFn := reflectedFn{
-		fn: v,
-		in: in,
+		fn:   v,
+		in:   in,
+		name: runtime.FuncForPC(v.Pointer()).Name(),
 	}
 
 	switch t.NumOut() {
@@ -39,6 +41,7 @@ func reflectFunc(fn interface{}) *reflectedFn {
 				return nil, err
 			}
 		} else {
+			rFn.out = t.Out(0)
 			rFn.outFn = func(out []reflect.Value) (interface{}, error) {
 				return out[0].Interface(), nil
 			}
@@ -48,6 +51,7 @@ func reflectFunc(fn interface{}) *reflectedFn {
 			panic(fmt.Sprintf("function %s second return value must be an error", t.Name()))
 		}
 
+		rFn.out = t.Out(0)
 		rFn.outFn = func(out []reflect.Value) (interface{}, error) {
 			err, _ := out[1].Interface().(error)
 
@@ -63,6 +67,8 @@ func reflectFunc(fn interface{}) *reflectedFn {
 type reflectedFn struct {
 	fn    reflect.Value
 	in    []reflect.Type
+	out   reflect.Type // The type of the value returned, if any
+	name  string       // The name of the function, like github.com/acme/orders.CreateOrder
 	outFn func(out []reflect.Value) (interface{}, error)
 }
 
@@ -1,10 +1,10 @@
 module github.com/mnavarrocarter/jsonapi
 
-go 1.17
-
-require github.com/xeipuuv/gojsonschema v1.2.0
+go 1.21
 
 require (
-	github.com/xeipuuv/gojsonpointer v0.0.0-20190905194746-02993c407bfb // indirect
-	github.com/xeipuuv/gojsonreference v0.0.0-20180127040603-bd5ef7bd5415 // indirect
+	github.com/xeipuuv/gojsonreference v0.0.0-20180127040603-bd5ef7bd5415
+	github.com/xeipuuv/gojsonschema v1.2.0
 )
+
+require github.com/xeipuuv/gojsonpointer v0.0.0-20190905194746-02993c407bfb // indirect
@@ -3,8 +3,10 @@ package jsonapi
 import (
 	"errors"
 	"io"
+	"log/slog"
 	"net/http"
 	"reflect"
+	"time"
 )
 
 // A JsonHandler wraps a function in
@@ -15,9 +17,39 @@ import (
 // any json body into a struct.
 type JsonHandler struct {
 	fn               *reflectedFn     // The wrapper over the reflected function
+	api              *API             // The API the handler was created from
+	Name             string           // The name of the handler in logs and metrics. When empty, the name of the function is used
 	RequestValidator RequestValidator // The validator for the request
 	ArgumentResolver ArgumentResolver // The argument resolver to be used
-	SkipPanic        bool             // Whether to skip panics or not
+	SkipPanic        bool             // Whether to let panics go up instead of recovering from them
+	PanicReporter    PanicReporter    // The reporter of recovered panics. When nil, the one of the API is used
+	Debug            bool             // Whether to include debug info in error responses. See WithDebug
+	Logger           *slog.Logger     // The logger of the requests. When nil, the one of the API is used, if any
+	LogLevels        *LogLevels       // The levels of the logged requests. When nil, DefaultLogLevels are used
+	Metrics          *Metrics         // The collector of the metrics. When nil, the one of the API is used, if any
+	Tracer           Tracer           // The tracer of the requests. When nil, the one of the API is used, or NoopTracer
+	RequestIDHeader  string           // The header of the request ID. When empty, the one of the API is used, if any
+	Timeout          time.Duration    // The time the function has to return. When zero, the one of the API is used, if any
+	ClientTimeout    bool             // Whether to honor the Request-Timeout header. See WithClientTimeout
+	RateLimiter      RateLimiter      // The limiter of the requests. See WithRateLimit
+	RateLimitKey     KeyFunc          // The key of the clients for the limiter. When nil, ByIP is used
+	IdempotencyStore IdempotencyStore // The store of the responses of idempotent requests. See WithIdempotency
+	IdempotencyScope KeyFunc          // The client the idempotency keys belong to. When nil, keys are shared by all clients
+	ServerTiming     bool             // Whether to send the Server-Timing header. See WithServerTiming
+	ETag             ETagMode         // The ETag computed for the responses, if any. See WithETag
+	CacheControl     string           // The Cache-Control header of the successful responses. See WithCacheControl
+	IfMatchRequired  bool             // Whether updates must come with an If-Match header. See WithIfMatchRequired
+	CacheTTL         time.Duration    // The time successful responses are cached for, if any. See WithCache
+	CacheKey         KeyFunc          // The key of the cached responses. When nil, ByURLAndCredentials is used
+	CacheStore       CacheStore       // The store of the cached responses. When nil, the one of the API is used, or DefaultCache
+	PageLimit        int              // The limit of the pages when the request has none. When zero, DefaultPageLimit is used
+	MaxPageLimit     int              // The maximum limit of the pages. When zero, DefaultMaxPageLimit is used
+	SparseFields     bool             // Whether clients can ask for only some fields of the responses. See WithSparseFields
+	AllowedFields    []string         // The fields clients can ask for. When empty, all the fields of the responses are allowed
+	ErrorFormat      ErrorFormat      // The format of the errors. When zero, DefaultErrorFormat is used
+	ErrorMappings    []ErrorMapping   // The error mappings, checked before the global ErrorMappings
+
+	inFlight flightGroup // The requests computing a response for a CacheStore that does not keep them
 }
 
 func (h *JsonHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
@@ -25,28 +57,122 @@ func (h *JsonHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		_ = c.Close()
 	}(req.Body)
 
+	start := time.Now()
+	req = withHandler(req, h)
+	req = withRequestID(w, req, h.requestIDHeader())
+
+	var tw *timingWriter
+	if h.serverTiming() {
+		var timings *serverTimings
+		req, timings = withTimings(req)
+		tw = &timingWriter{bufferedWriter: bufferedWriter{ResponseWriter: w}, timings: timings}
+		w = tw
+	}
+
+	rw := newResponseWriter(w)
+
+	req, root := h.startSpan(req)
+	defer func() {
+		root.SetAttribute("http.response.status_code", rw.statusCode())
+		root.End()
+	}()
+
+	if h.logger() != nil {
+		defer h.logRequest(rw, req, start)
+	}
+
+	if m := h.metrics(); m != nil {
+		m.requestStarted(h.name())
+		defer func() {
+			m.requestServed(h.name(), rw.statusCode(), time.Since(start))
+		}()
+	}
+
+	if tw != nil {
+		defer func() {
+			tw.flush(time.Since(start))
+		}()
+	}
+
+	// Any panic, from validators, resolvers, the function or the response sender, ends here
+	defer h.recoverPanic(rw, req)
+
+	req, cancel := h.withTimeout(req)
+	defer cancel()
+
+	if err := h.rateLimit(rw, req); err != nil {
+		h.handleError(rw, req, err)
+		return
+	}
+
+	if err := h.requirePrecondition(req); err != nil {
+		h.handleError(rw, req, err)
+		return
+	}
+
+	// The fields are checked against the type the function returns, so it is not called
+	// for a response the client cannot get
+	fields, items := h.sparseFields(req)
+	if len(items) != 0 {
+		h.sendResponse(rw, req, items)
+		return
+	}
+
+	stateFrom(req).fields = fields
+
+	// The body is cached, so it is read only once, and decoded into a document only once
+	// across validators. See decodeBody.
+	withBody(req)
+
+	finish, done := h.idempotent(rw, req)
+	if done {
+		return
+	}
+
+	defer finish()
+
 	// Validate the request
 	if h.RequestValidator != nil {
+		span := h.startChildSpan(req, "validate")
 		items, err := h.RequestValidator.Validate(req)
+		span.SetAttribute("validation.errors", len(items))
+		span.End()
+
 		if errors.Is(err, ErrEmptyBody) {
-			HandleError(w, req, &apiError{
-				code: http.StatusBadRequest,
-				msg:  "Request body cannot be empty",
+			h.handleError(rw, req, &apiError{
+				code:    http.StatusBadRequest,
+				msg:     "Request body cannot be empty",
+				errCode: "empty_body",
+				kind:    KindInvalidRequest,
 			})
 			return
 		}
 
+		// Validators reject the requests they cannot validate, like malformed bodies, with
+		// client errors
+		var c Coder
+		if errors.As(err, &c) && c.Code() < http.StatusInternalServerError {
+			h.handleError(rw, req, err)
+			return
+		}
+
 		if err != nil {
-			HandleError(w, req, &apiError{
-				code: http.StatusInternalServerError,
-				msg:  "There was an error while validating the request",
-				prev: err,
+			h.handleError(rw, req, &apiError{
+				code:    http.StatusInternalServerError,
+				msg:     "There was an error while validating the request",
+				errCode: "validation_error",
+				kind:    KindInternal,
+				prev:    err,
 			})
 			return
 		}
 
 		if len(items) != 0 {
-			SendResponse(w, req, items)
+			if m := h.metrics(); m != nil {
+				m.validationFailed(h.name())
+			}
+
+			h.sendResponse(rw, req, items)
 			return
 		}
 	}
@@ -55,21 +181,54 @@ func (h *JsonHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	args := make([]reflect.Value, 0, len(h.fn.in))
 
 	for i, t := range h.fn.in {
+		span := h.startChildSpan(req, "resolve")
+		span.SetAttribute("argument.position", i)
+		span.SetAttribute("argument.type", t.String())
 		v, err := h.ArgumentResolver.Resolve(req, t, i)
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+
 		if errors.Is(err, ErrEmptyBody) {
-			HandleError(w, req, &apiError{
-				code: http.StatusBadRequest,
-				msg:  "Request body cannot be empty",
-				prev: err,
+			h.handleError(rw, req, &apiError{
+				code:    http.StatusBadRequest,
+				msg:     "Request body cannot be empty",
+				errCode: "empty_body",
+				kind:    KindInvalidRequest,
+				prev:    err,
 			})
 			return
 		}
 
+		if isDecodeError(err) {
+			h.handleError(rw, req, &apiError{
+				code:    http.StatusBadRequest,
+				msg:     "Error while validating the request",
+				errCode: "invalid_body",
+				kind:    KindInvalidRequest,
+				prev:    err,
+			})
+			return
+		}
+
+		var ve *ValidationError
+		if errors.As(err, &ve) {
+			if m := h.metrics(); m != nil {
+				m.validationFailed(h.name())
+			}
+
+			h.sendResponse(rw, req, ve.Items)
+			return
+		}
+
 		if err != nil {
-			HandleError(w, req, &apiError{
-				code: http.StatusInternalServerError,
-				msg:  "Error while trying to resolve handler arguments",
-				prev: err,
+			h.handleError(rw, req, &apiError{
+				code:    http.StatusInternalServerError,
+				msg:     "Error while trying to resolve handler arguments",
+				errCode: "argument_resolution",
+				kind:    KindInternal,
+				prev:    err,
 			})
 			return
 		}
@@ -77,20 +236,126 @@ func (h *JsonHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		args = append(args, v)
 	}
 
-	defer func() {
-		if r := recover(); r != nil && h.SkipPanic == false {
-			err := panicToError(r)
-			HandleError(w, req, err)
+	// Only the requests that passed validation and argument resolution are served from the cache
+	finish, done = h.cached(rw, req)
+	if done {
+		return
+	}
+
+	defer finish()
+
+	// The function gets the context of the call span, so the spans it opens are its children
+	ctx, span := h.startChildSpanContext(req, "call")
+	callReq := req.WithContext(ctx)
+
+	for i := range args {
+		if h.fn.in[i] == contextType && args[i].Interface() == req.Context() {
+			args[i] = reflect.ValueOf(&ctx).Elem()
 		}
-	}()
+	}
 
-	out, err := h.fn.call(args)
+	out, err := h.call(callReq, args)
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
 
 	if err != nil {
-		HandleError(w, req, err)
+		h.handleError(rw, req, err)
 		return
 	}
 
-	SendResponse(w, req, out)
+	h.sendResponse(rw, req, out)
 	return
 }
+
+// WithName sets the name of the handler in logs and metrics, like "create-order"
+func WithName(name string) OptsFn {
+	return func(h *JsonHandler) {
+		h.Name = name
+	}
+}
+
+func (h *JsonHandler) name() string {
+	if h.Name != "" {
+		return h.Name
+	}
+
+	return h.fn.name
+}
+
+// handleError records the error the request failed with and handles it
+//
+// Nothing is sent if the client went away.
+func (h *JsonHandler) handleError(w *responseWriter, req *http.Request, err error) {
+	stateFrom(req).err = err
+	SpanFromContext(req.Context()).RecordError(err)
+
+	if clientGone(req) {
+		w.status = StatusClientClosedRequest
+		return
+	}
+
+	span := h.startChildSpan(req, "encode")
+	defer span.End()
+
+	h.api.handleError(w, req, err)
+}
+
+// sendResponse sends the response of the request
+//
+// Nothing is sent if the client went away.
+func (h *JsonHandler) sendResponse(w *responseWriter, req *http.Request, v interface{}) {
+	if clientGone(req) {
+		w.status = StatusClientClosedRequest
+		return
+	}
+
+	span := h.startChildSpan(req, "encode")
+	defer span.End()
+
+	// The validators are taken from the value the function returned, not from what is sent
+	src := v
+	fields := stateFrom(req).fields
+
+	if p, ok := v.(pager); ok {
+		v = renderPage(w, req, p)
+
+		// The fields of a page are the fields of its items
+		if fields != nil {
+			fields = fieldTree{"items": fields, "meta": nil}
+		}
+	}
+
+	switch v.(type) {
+	case nil, error, []*ErrorItem:
+	default:
+		if fields != nil {
+			projected, err := projectFields(v, fields)
+			if err != nil {
+				h.handleError(w, req, &apiError{
+					code:    http.StatusInternalServerError,
+					msg:     "There was an error while encoding the response",
+					errCode: "encoding_error",
+					kind:    KindInternal,
+					prev:    err,
+				})
+				return
+			}
+
+			v = projected
+
+			// The projection does not implement the interfaces of the value
+			if c, ok := src.(Coder); ok {
+				v = &codedValue{value: projected, code: c.Code()}
+			}
+		}
+	}
+
+	if h.conditional(req, src) || stateFrom(req).cache != nil {
+		h.sendConditional(w, req, v, src)
+		return
+	}
+
+	h.api.sendResponse(w, req, v)
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/mnavarrocarter/jsonapi"
@@ -87,7 +88,7 @@ func TestHandler(t *testing.T) {
 			handler: func(ctx *customContext) {
 				panic("should not reach here")
 			},
-			expectedResponse: []byte(`{"status":500,"details":"Error while trying to resolve handler arguments"}` + "\n"),
+			expectedResponse: []byte(`{"status":500,"kind":"Internal Error","code":"argument_resolution","details":"Error while trying to resolve handler arguments","reference":"f00dcafe"}` + "\n"),
 			expectedStatus:   http.StatusInternalServerError,
 		},
 		{
@@ -98,7 +99,7 @@ func TestHandler(t *testing.T) {
 			handler: func(cmd *testCmd) *testResp {
 				return &testResp{Msg: "success"}
 			},
-			expectedResponse: []byte(`{"status":400,"details":"Request body cannot be empty"}` + "\n"),
+			expectedResponse: []byte(`{"status":400,"kind":"Invalid Request","code":"empty_body","details":"Request body cannot be empty"}` + "\n"),
 			expectedStatus:   http.StatusBadRequest,
 		},
 		{
@@ -131,7 +132,7 @@ func TestHandler(t *testing.T) {
 			handler: func() error {
 				return errors.New("there was an error")
 			},
-			expectedResponse: []byte(`{"status":500,"details":"there was an error"}` + "\n"),
+			expectedResponse: []byte(`{"status":500,"kind":"Internal Error","code":"unexpected_error","details":"An unexpected error has occurred","reference":"f00dcafe"}` + "\n"),
 			expectedStatus:   http.StatusInternalServerError,
 		},
 		{
@@ -166,7 +167,7 @@ func TestHandler(t *testing.T) {
 			handler: func() error {
 				panic("something really bad has happened")
 			},
-			expectedResponse: []byte(`{"status":500,"details":"An unexpected error has occurred"}` + "\n"),
+			expectedResponse: []byte(`{"status":500,"kind":"Internal Error","code":"unexpected_error","details":"An unexpected error has occurred","reference":"f00dcafe"}` + "\n"),
 			expectedStatus:   http.StatusInternalServerError,
 		},
 		{
@@ -188,7 +189,7 @@ func TestHandler(t *testing.T) {
 			handler: func() (*testResp, error) {
 				return nil, errors.New("there was an error")
 			},
-			expectedResponse: []byte(`{"status":500,"details":"there was an error"}` + "\n"),
+			expectedResponse: []byte(`{"status":500,"kind":"Internal Error","code":"unexpected_error","details":"An unexpected error has occurred","reference":"f00dcafe"}` + "\n"),
 			expectedStatus:   http.StatusInternalServerError,
 		},
 		{
@@ -210,7 +211,35 @@ func TestHandler(t *testing.T) {
 			}(),
 			schema:           mustOpen(t, "schema.json"),
 			handler:          func() {},
-			expectedResponse: []byte(`{"status":400,"details":"Validation errors","errors":[{"field":"id","value":"3f2476fd2b-270f-4baa-81c9-01e91fc87fd3","msg":"Does not match format 'uuid'"},{"field":"name","value":"","msg":"String length must be greater than or equal to 1"}]}` + "\n"),
+			expectedResponse: []byte(`{"status":400,"kind":"Invalid Request","code":"validation_failed","details":"Validation errors","errors":[{"field":"id","pointer":"/id","source":"body","code":"format","params":{"format":"uuid"},"value":"3f2476fd2b-270f-4baa-81c9-01e91fc87fd3","msg":"Does not match format 'uuid'"},{"field":"name","pointer":"/name","source":"body","code":"min_length","params":{"min":1},"value":"","msg":"String length must be greater than or equal to 1"}]}` + "\n"),
+			expectedStatus:   http.StatusBadRequest,
+		},
+		{
+			name: "malformed body with schema",
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "https://example.com", strings.NewReader(`{bad`))
+			}(),
+			schema:           mustOpen(t, "schema.json"),
+			handler:          func(cmd *testCmd) {},
+			expectedResponse: []byte(`{"status":400,"kind":"Invalid Request","code":"invalid_body","details":"Error while validating the request"}` + "\n"),
+			expectedStatus:   http.StatusBadRequest,
+		},
+		{
+			name: "malformed body",
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "https://example.com", strings.NewReader(`{bad`))
+			}(),
+			handler:          func(cmd *testCmd) {},
+			expectedResponse: []byte(`{"status":400,"kind":"Invalid Request","code":"invalid_body","details":"Error while validating the request"}` + "\n"),
+			expectedStatus:   http.StatusBadRequest,
+		},
+		{
+			name: "body of the wrong types",
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "https://example.com", strings.NewReader(`{"name":5}`))
+			}(),
+			handler:          func(cmd *testCmd) {},
+			expectedResponse: []byte(`{"status":400,"kind":"Invalid Request","code":"invalid_body","details":"Error while validating the request"}` + "\n"),
 			expectedStatus:   http.StatusBadRequest,
 		},
 		{
@@ -221,7 +250,7 @@ func TestHandler(t *testing.T) {
 			handler: func(ctx context.Context, cmd *testCmd, params map[string]string) *testResp {
 				panic("should not reach here")
 			},
-			expectedResponse: []byte(`{"status":500,"details":"Error while trying to resolve handler arguments"}` + "\n"),
+			expectedResponse: []byte(`{"status":500,"kind":"Internal Error","code":"argument_resolution","details":"Error while trying to resolve handler arguments","reference":"f00dcafe"}` + "\n"),
 			expectedStatus:   http.StatusInternalServerError,
 		},
 		{
@@ -241,7 +270,7 @@ func TestHandler(t *testing.T) {
 	for _, test := range tt {
 		t.Run(test.name, func(t *testing.T) {
 			rec := httptest.NewRecorder()
-			handler := jsonapi.Wrap(test.handler, jsonapi.WithSchema(test.schema))
+			handler := testAPI().Wrap(test.handler, jsonapi.WithSchema(test.schema))
 
 			if test.skipPanic {
 				handler.SkipPanic = test.skipPanic
@@ -0,0 +1,220 @@
+package jsonapi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+// IdempotencyKeyHeader is the header clients send the idempotency key of their requests in
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set in the responses replayed from an IdempotencyStore
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// An IdempotencyStore keeps the responses of the requests sent with an idempotency key,
+// so they can be replayed when the requests are retried.
+type IdempotencyStore interface {
+	// Reserve reserves a key for a request. If the key has already been reserved, it returns
+	// its record and false.
+	//
+	// It must be atomic: only one of the requests reserving the same key can succeed.
+	Reserve(key, fingerprint string) (*IdempotencyRecord, bool, error)
+	// Save stores the response of the request the key was reserved for
+	Save(key string, record *IdempotencyRecord) error
+	// Release frees a key, so the request can be retried
+	Release(key string) error
+}
+
+// An IdempotencyRecord is what an IdempotencyStore keeps of a request
+type IdempotencyRecord struct {
+	Fingerprint string      `json:"fingerprint"` // The fingerprint of the request
+	Done        bool        `json:"done"`        // Whether the response has been sent, false while in flight
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// WithIdempotency makes the handler idempotent for the requests with an Idempotency-Key
+// header, so clients can safely retry them.
+//
+// The first response for a key is kept in the store and replayed to the requests that come
+// later with the same key, without calling the function. While the first request is
+// being served, retries get a 409 error. Requests that reuse a key with a different
+// method, path or body get a 422 error.
+//
+// Server errors are not kept, so the request can be retried. After a timeout, the key is
+// kept reserved until the function returns, so retries are not served while it still runs.
+// Safe methods, like GET, are not affected.
+//
+// Keys are shared by all clients: a client that sends the key and the body of the request of
+// another one gets its response. Use WithIdempotencyScope when keys are not secret.
+func WithIdempotency(store IdempotencyStore) OptsFn {
+	return func(h *JsonHandler) {
+		h.IdempotencyStore = store
+	}
+}
+
+// WithIdempotencyScope makes the idempotency keys belong to the clients identified by key,
+// like ByContextValue for the principal set by an authentication middleware, so a client
+// never gets the responses of another one. See WithIdempotency.
+//
+// Requests whose key is empty share their idempotency keys.
+func WithIdempotencyScope(key KeyFunc) OptsFn {
+	return func(h *JsonHandler) {
+		h.IdempotencyScope = key
+	}
+}
+
+// fingerprint identifies the contents of a request
+func fingerprint(req *http.Request, body []byte) string {
+	sum := sha256.New()
+	sum.Write([]byte(req.Method))
+	sum.Write([]byte{0})
+	sum.Write([]byte(req.URL.RequestURI()))
+	sum.Write([]byte{0})
+	sum.Write(body)
+
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+// idempotent reserves the idempotency key of a request.
+//
+// It returns the function that stores the response once the request has been served. If
+// the request must not be served, because its response has been replayed or because of
+// an error, done is true.
+func (h *JsonHandler) idempotent(w *responseWriter, req *http.Request) (finish func(), done bool) {
+	key := req.Header.Get(IdempotencyKeyHeader)
+	if h.IdempotencyStore == nil || key == "" || isSafeMethod(req.Method) {
+		return func() {}, false
+	}
+
+	body, err := readBody(req)
+	if err != nil {
+		h.handleError(w, req, err)
+		return nil, true
+	}
+
+	fp := fingerprint(req, body)
+
+	if h.IdempotencyScope != nil {
+		key = h.IdempotencyScope(req) + "\x00" + key
+	}
+
+	record, ok, err := h.IdempotencyStore.Reserve(key, fp)
+	if err != nil {
+		h.handleError(w, req, err)
+		return nil, true
+	}
+
+	if !ok {
+		h.replay(w, req, fp, record)
+		return nil, true
+	}
+
+	cw := &captureWriter{ResponseWriter: w.ResponseWriter}
+	w.ResponseWriter = cw
+
+	return func() {
+		// Server errors, panics and requests the client gave up on can be retried, once the
+		// function has returned
+		if cw.status == 0 || cw.status >= http.StatusInternalServerError || w.status == StatusClientClosedRequest {
+			if running := stateFrom(req).running; running != nil {
+				go func() {
+					<-running
+					_ = h.IdempotencyStore.Release(key)
+				}()
+
+				return
+			}
+
+			_ = h.IdempotencyStore.Release(key)
+			return
+		}
+
+		_ = h.IdempotencyStore.Save(key, &IdempotencyRecord{
+			Fingerprint: fp,
+			Done:        true,
+			Status:      cw.status,
+			Header:      cw.header,
+			Body:        cw.body.Bytes(),
+		})
+	}, false
+}
+
+// replay sends the response kept for a key, or an error if it cannot be replayed
+func (h *JsonHandler) replay(w *responseWriter, req *http.Request, fp string, record *IdempotencyRecord) {
+	if record.Fingerprint != fp {
+		h.handleError(w, req, &apiError{
+			code:    http.StatusUnprocessableEntity,
+			msg:     "The idempotency key has already been used for a different request",
+			errCode: "idempotency_key_reused",
+			kind:    KindInvalidRequest,
+		})
+		return
+	}
+
+	if !record.Done {
+		h.handleError(w, req, &apiError{
+			code:    http.StatusConflict,
+			msg:     "A request with the same idempotency key is being processed",
+			errCode: "idempotency_in_flight",
+			kind:    KindConflict,
+		})
+		return
+	}
+
+	// The headers of this request, like its ID, take precedence
+	for k, v := range record.Header {
+		if _, ok := w.Header()[k]; !ok {
+			w.Header()[k] = v
+		}
+	}
+
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(record.Status)
+	_, _ = w.Write(record.Body)
+}
+
+// captureWriter keeps a copy of the response it writes
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *captureWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.header = w.Header().Clone()
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	w.body.Write(b)
+
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
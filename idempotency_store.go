@@ -0,0 +1,380 @@
+package jsonapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultIdempotencyTTL is the time idempotency records are kept for, when none is given
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// MemoryIdempotencyStore is an IdempotencyStore that keeps the records in memory.
+//
+// Records are forgotten after their TTL. Records of requests in flight are kept for the
+// TTL too, in case the instance serving them never releases them. Expired records are
+// ignored when they are looked up, and removed every few reservations.
+type MemoryIdempotencyStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	records  map[string]*memoryRecord
+	reserved int // The reservations since the expired records were last removed
+}
+
+// sweepInterval is the number of reservations after which the stores remove their expired
+// records, so each reservation does not have to go through them all
+const sweepInterval = 1000
+
+type memoryRecord struct {
+	record  IdempotencyRecord
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore makes a MemoryIdempotencyStore. When ttl is zero,
+// DefaultIdempotencyTTL is used.
+func NewMemoryIdempotencyStore(ttl time.Duration) *MemoryIdempotencyStore {
+	if ttl == 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+
+	return &MemoryIdempotencyStore{
+		ttl:     ttl,
+		records: make(map[string]*memoryRecord),
+	}
+}
+
+func (s *MemoryIdempotencyStore) Reserve(key, fingerprint string) (*IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	s.reserved++
+	if s.reserved >= sweepInterval {
+		s.sweep(now)
+	}
+
+	if r, ok := s.records[key]; ok && !now.After(r.expires) {
+		record := r.record
+		return &record, false, nil
+	}
+
+	s.records[key] = &memoryRecord{
+		record:  IdempotencyRecord{Fingerprint: fingerprint},
+		expires: now.Add(s.ttl),
+	}
+
+	return nil, true, nil
+}
+
+// sweep removes the expired records
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	for k, r := range s.records {
+		if now.After(r.expires) {
+			delete(s.records, k)
+		}
+	}
+
+	s.reserved = 0
+}
+
+func (s *MemoryIdempotencyStore) Save(key string, record *IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[key] = &memoryRecord{record: *record, expires: time.Now().Add(s.ttl)}
+
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+
+	return nil
+}
+
+// FileIdempotencyStore is an IdempotencyStore that keeps each record in a json file of a
+// directory, so they survive restarts and can be shared by the instances of a host.
+//
+// Records are forgotten after their TTL. Expired records are replaced when their key is
+// reserved again, and removed every few reservations.
+type FileIdempotencyStore struct {
+	dir      string
+	ttl      time.Duration
+	reserved atomic.Int64 // The reservations since the expired records were last removed
+}
+
+// NewFileIdempotencyStore makes a FileIdempotencyStore in dir, which is created if it does
+// not exist. When ttl is zero, DefaultIdempotencyTTL is used.
+func NewFileIdempotencyStore(dir string, ttl time.Duration) (*FileIdempotencyStore, error) {
+	if ttl == 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("could not create idempotency store: %w", err)
+	}
+
+	return &FileIdempotencyStore{dir: dir, ttl: ttl}, nil
+}
+
+// path returns the file of a key. Keys are hashed, as they come from clients.
+func (s *FileIdempotencyStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (s *FileIdempotencyStore) Reserve(key, fingerprint string) (*IdempotencyRecord, bool, error) {
+	if s.reserved.Add(1) >= sweepInterval {
+		s.reserved.Store(0)
+		s.sweep()
+	}
+
+	path := s.path(key)
+
+	// The file is created only if it does not exist, so only one request can reserve the key
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		record, expired, err := s.read(path)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if expired {
+			return s.reclaim(path, fingerprint)
+		}
+
+		// The request that reserved it has not written it yet
+		if record.Fingerprint == "" {
+			record.Fingerprint = fingerprint
+		}
+
+		return record, false, nil
+	}
+
+	if err != nil {
+		return nil, false, fmt.Errorf("could not reserve idempotency key: %w", err)
+	}
+
+	defer f.Close()
+
+	if err := json.NewEncoder(f).Encode(&IdempotencyRecord{Fingerprint: fingerprint}); err != nil {
+		return nil, false, fmt.Errorf("could not reserve idempotency key: %w", err)
+	}
+
+	return nil, true, nil
+}
+
+// reclaim reserves a key whose record has expired. The record is checked again and
+// replaced under the lock of the key, so only one of the requests that found it expired
+// reserves it. The others are told it is in flight.
+func (s *FileIdempotencyStore) reclaim(path, fingerprint string) (*IdempotencyRecord, bool, error) {
+	unlock, ok, err := s.lock(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not reserve idempotency key: %w", err)
+	}
+
+	if !ok {
+		return &IdempotencyRecord{Fingerprint: fingerprint}, false, nil
+	}
+
+	defer unlock()
+
+	info, err := os.Stat(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, false, fmt.Errorf("could not reserve idempotency key: %w", err)
+	}
+
+	reservation := &IdempotencyRecord{Fingerprint: fingerprint}
+
+	// The record was released meanwhile, so the key is reserved as usual, in case another
+	// request is reserving it too
+	if err != nil {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, fs.ErrExist) {
+			return reservation, false, nil
+		}
+
+		if err != nil {
+			return nil, false, fmt.Errorf("could not reserve idempotency key: %w", err)
+		}
+
+		defer f.Close()
+
+		if err := json.NewEncoder(f).Encode(reservation); err != nil {
+			return nil, false, fmt.Errorf("could not reserve idempotency key: %w", err)
+		}
+
+		return nil, true, nil
+	}
+
+	// The key was reclaimed by another request before the lock was taken
+	if time.Since(info.ModTime()) <= s.ttl {
+		record, _, err := s.read(path)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if record == nil || record.Fingerprint == "" {
+			return reservation, false, nil
+		}
+
+		return record, false, nil
+	}
+
+	if err := s.write(path, reservation); err != nil {
+		return nil, false, fmt.Errorf("could not reserve idempotency key: %w", err)
+	}
+
+	return nil, true, nil
+}
+
+// lock takes the lock of the file of a key, used to replace or remove it once it has
+// expired. It returns false if the lock is already taken.
+func (s *FileIdempotencyStore) lock(path string) (func(), bool, error) {
+	name := strings.TrimSuffix(path, ".json") + ".lock"
+
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	_ = f.Close()
+
+	return func() { _ = os.Remove(name) }, true, nil
+}
+
+// sweep removes the expired records, and the locks and temporary files left behind by the
+// instances that stopped while using them
+func (s *FileIdempotencyStore) sweep() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || time.Since(info.ModTime()) <= s.ttl {
+			continue
+		}
+
+		path := filepath.Join(s.dir, e.Name())
+
+		switch filepath.Ext(path) {
+		case ".json":
+			s.removeExpired(path)
+		case ".lock", ".tmp":
+			_ = os.Remove(path)
+		}
+	}
+}
+
+// removeExpired removes the file of a record if it is still expired once its lock is taken
+func (s *FileIdempotencyStore) removeExpired(path string) {
+	unlock, ok, err := s.lock(path)
+	if err != nil || !ok {
+		return
+	}
+
+	defer unlock()
+
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > s.ttl {
+		_ = os.Remove(path)
+	}
+}
+
+// read reads the record in a file, and tells whether it has expired
+func (s *FileIdempotencyStore) read(path string) (*IdempotencyRecord, bool, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, true, nil
+	}
+
+	if err != nil {
+		return nil, false, fmt.Errorf("could not read idempotency record: %w", err)
+	}
+
+	if time.Since(info.ModTime()) > s.ttl {
+		return nil, true, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not read idempotency record: %w", err)
+	}
+
+	record := &IdempotencyRecord{}
+
+	// The file of a key that has just been reserved may still be empty
+	if err := json.Unmarshal(b, record); err != nil {
+		return &IdempotencyRecord{}, false, nil
+	}
+
+	return record, false, nil
+}
+
+func (s *FileIdempotencyStore) Save(key string, record *IdempotencyRecord) error {
+	if err := s.write(s.path(key), record); err != nil {
+		return fmt.Errorf("could not save idempotency record: %w", err)
+	}
+
+	return nil
+}
+
+// write writes a record to its file. It is written to a temporary file first, and then
+// renamed, so readers never see it half written.
+func (s *FileIdempotencyStore) write(path string, record *IdempotencyRecord) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "record-*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+func (s *FileIdempotencyStore) Release(key string) error {
+	err := os.Remove(s.path(key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not release idempotency key: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,296 @@
+package jsonapi_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mnavarrocarter/jsonapi"
+)
+
+func TestWithIdempotency(t *testing.T) {
+	fileStore, err := jsonapi.NewFileIdempotencyStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]jsonapi.IdempotencyStore{
+		"memory": jsonapi.NewMemoryIdempotencyStore(0),
+		"file":   fileStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			failing := true
+
+			handler := testAPI().Wrap(func(cmd *testCmd) (*testResp, error) {
+				calls++
+
+				if cmd.Name == "flaky" && failing {
+					failing = false
+					return nil, errors.New("connection refused")
+				}
+
+				return &testResp{Msg: fmt.Sprintf("created %s #%d", cmd.Name, calls)}, nil
+			}, jsonapi.WithIdempotency(store))
+
+			send := func(method, key, body string) *httptest.ResponseRecorder {
+				rec := httptest.NewRecorder()
+				req := httptest.NewRequest(method, "/plans", strings.NewReader(body))
+
+				if key != "" {
+					req.Header.Set(jsonapi.IdempotencyKeyHeader, key)
+				}
+
+				handler.ServeHTTP(rec, req)
+
+				return rec
+			}
+
+			expect := func(t *testing.T, rec *httptest.ResponseRecorder, status int, body string, replayed bool) {
+				t.Helper()
+
+				if status != rec.Code {
+					t.Errorf("expected status %d does not match received %d", status, rec.Code)
+				}
+
+				if body != rec.Body.String() {
+					t.Errorf("response body does not match\nexpected: %s\nreceived: %s\n", body, rec.Body.String())
+				}
+
+				if r := rec.Header().Get(jsonapi.IdempotentReplayedHeader) == "true"; replayed != r {
+					t.Errorf("expected replayed %v does not match received %v", replayed, r)
+				}
+			}
+
+			// The first request is served, and its retries replayed
+			expect(t, send(http.MethodPost, "key-1", `{"name":"gold"}`), http.StatusOK, `{"msg":"created gold #1"}`+"\n", false)
+			replay := send(http.MethodPost, "key-1", `{"name":"gold"}`)
+			expect(t, replay, http.StatusOK, `{"msg":"created gold #1"}`+"\n", true)
+
+			if ct := replay.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected the headers to be replayed, got content type %q", ct)
+			}
+
+			// The same key with another payload
+			expect(t, send(http.MethodPost, "key-1", `{"name":"silver"}`), http.StatusUnprocessableEntity,
+				`{"status":422,"kind":"Invalid Request","code":"idempotency_key_reused","details":"The idempotency key has already been used for a different request"}`+"\n", false)
+
+			// Without key, or with a safe method, requests are always served
+			expect(t, send(http.MethodPost, "", `{"name":"gold"}`), http.StatusOK, `{"msg":"created gold #2"}`+"\n", false)
+			expect(t, send(http.MethodGet, "key-1", `{"name":"gold"}`), http.StatusOK, `{"msg":"created gold #3"}`+"\n", false)
+
+			// Server errors are not kept, so they can be retried
+			expect(t, send(http.MethodPost, "key-2", `{"name":"flaky"}`), http.StatusInternalServerError,
+				`{"status":500,"kind":"Internal Error","code":"unexpected_error","details":"An unexpected error has occurred","reference":"f00dcafe"}`+"\n", false)
+			expect(t, send(http.MethodPost, "key-2", `{"name":"flaky"}`), http.StatusOK, `{"msg":"created flaky #5"}`+"\n", false)
+
+			// A key reserved by another request, still in flight
+			if _, ok, err := store.Reserve("key-3", "in flight"); err != nil || !ok {
+				t.Fatalf("could not reserve key: %v", err)
+			}
+
+			expect(t, send(http.MethodPost, "key-3", `{"name":"gold"}`), http.StatusUnprocessableEntity,
+				`{"status":422,"kind":"Invalid Request","code":"idempotency_key_reused","details":"The idempotency key has already been used for a different request"}`+"\n", false)
+
+			if calls != 5 {
+				t.Errorf("expected the function to be called 5 times, got %d", calls)
+			}
+		})
+	}
+}
+
+func TestWithIdempotency_InFlight(t *testing.T) {
+	store := jsonapi.NewMemoryIdempotencyStore(0)
+	var retry *httptest.ResponseRecorder
+
+	var handler *jsonapi.JsonHandler
+	handler = jsonapi.Wrap(func(cmd *testCmd) *testResp {
+		// The client retries while the first request is being served
+		if retry == nil {
+			retry = httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/plans", strings.NewReader(`{"name":"gold"}`))
+			req.Header.Set(jsonapi.IdempotencyKeyHeader, "key-1")
+			handler.ServeHTTP(retry, req)
+		}
+
+		return &testResp{Msg: "created"}
+	}, jsonapi.WithIdempotency(store))
+
+	req := httptest.NewRequest(http.MethodPost, "/plans", strings.NewReader(`{"name":"gold"}`))
+	req.Header.Set(jsonapi.IdempotencyKeyHeader, "key-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if retry.Code != http.StatusConflict {
+		t.Errorf("expected status %d does not match received %d", http.StatusConflict, retry.Code)
+	}
+
+	expected := `{"status":409,"kind":"Conflict","code":"idempotency_in_flight","details":"A request with the same idempotency key is being processed"}` + "\n"
+	if retry.Body.String() != expected {
+		t.Errorf("response body does not match\nexpected: %s\nreceived: %s\n", expected, retry.Body.String())
+	}
+}
+
+func TestMemoryIdempotencyStore_Expiry(t *testing.T) {
+	store := jsonapi.NewMemoryIdempotencyStore(10 * time.Millisecond)
+
+	if _, reserved, _ := store.Reserve("key-1", "a"); !reserved {
+		t.Fatal("expected the key to be reserved")
+	}
+
+	_ = store.Save("key-1", &jsonapi.IdempotencyRecord{Fingerprint: "a", Done: true, Status: http.StatusCreated})
+
+	if record, reserved, _ := store.Reserve("key-1", "a"); reserved || record.Status != http.StatusCreated {
+		t.Errorf("expected the record to be kept, got %v", record)
+	}
+
+	// Expired records are forgotten as soon as they are looked up
+	time.Sleep(20 * time.Millisecond)
+
+	if _, reserved, _ := store.Reserve("key-1", "b"); !reserved {
+		t.Error("expected the expired key to be reserved again")
+	}
+}
+
+func TestFileIdempotencyStore_Expiry(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := jsonapi.NewFileIdempotencyStore(dir, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, reserved, _ := store.Reserve("key-1", "a"); !reserved {
+		t.Fatal("expected the key to be reserved")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Only one of the requests that find the record expired reserves the key again
+	var wg sync.WaitGroup
+	var reservations atomic.Int64
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, reserved, err := store.Reserve("key-1", "b")
+			if err != nil {
+				t.Error(err)
+			}
+
+			if reserved {
+				reservations.Add(1)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if n := reservations.Load(); n != 1 {
+		t.Errorf("expected the expired key to be reserved once, got %d", n)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Expired records are removed every few reservations, even if their key never comes back
+	for i := 0; i < 1000; i++ {
+		_, _, _ = store.Reserve("key-2", "a")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("expected only the record of key-2 to be kept, got %d files", len(entries))
+	}
+}
+
+func TestWithIdempotency_Timeout(t *testing.T) {
+	store := jsonapi.NewMemoryIdempotencyStore(0)
+	unblock := make(chan struct{})
+	returned := make(chan struct{})
+
+	handler := jsonapi.Wrap(func(cmd *testCmd) *testResp {
+		defer close(returned)
+		<-unblock
+
+		return &testResp{Msg: "created"}
+	}, jsonapi.WithIdempotency(store), jsonapi.WithTimeout(10*time.Millisecond))
+
+	send := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/plans", strings.NewReader(`{"name":"gold"}`))
+		req.Header.Set(jsonapi.IdempotencyKeyHeader, "key-1")
+		handler.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	if rec := send(); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d does not match received %d", http.StatusServiceUnavailable, rec.Code)
+	}
+
+	// The function of the first request is still running
+	if rec := send(); rec.Code != http.StatusConflict {
+		t.Errorf("expected status %d does not match received %d", http.StatusConflict, rec.Code)
+	}
+
+	close(unblock)
+	<-returned
+
+	// The key is released once the function returns
+	for i := 0; i < 100; i++ {
+		if _, reserved, _ := store.Reserve("key-1", "a"); reserved {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Error("expected the key to be released once the function returned")
+}
+
+func TestWithIdempotencyScope(t *testing.T) {
+	calls := 0
+
+	handler := jsonapi.Wrap(func(cmd *testCmd) *testResp {
+		calls++
+		return &testResp{Msg: fmt.Sprintf("created %d", calls)}
+	}, jsonapi.WithIdempotency(jsonapi.NewMemoryIdempotencyStore(0)), jsonapi.WithIdempotencyScope(jsonapi.ByHeader("X-API-Key")))
+
+	tests := []struct {
+		name   string
+		apiKey string
+		body   string
+	}{
+		{name: "first client", apiKey: "client-1", body: `{"msg":"created 1"}` + "\n"},
+		{name: "another client with the same key", apiKey: "client-2", body: `{"msg":"created 2"}` + "\n"},
+		{name: "retry of the first client", apiKey: "client-1", body: `{"msg":"created 1"}` + "\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/plans", strings.NewReader(`{"name":"gold"}`))
+			req.Header.Set(jsonapi.IdempotencyKeyHeader, "key-1")
+			req.Header.Set("X-API-Key", test.apiKey)
+			handler.ServeHTTP(rec, req)
+
+			if test.body != rec.Body.String() {
+				t.Errorf("response body does not match\nexpected: %s\nreceived: %s\n", test.body, rec.Body.String())
+			}
+		})
+	}
+}
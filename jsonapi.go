@@ -7,16 +7,8 @@ type OptsFn func(h *JsonHandler)
 // See JsonHandler for documentation on how this handler works.
 //
 // Also, see Defaults to study the default implementations of the different components.
+//
+// To create handlers with a different configuration, see API.
 func Wrap(fn interface{}, opts ...OptsFn) *JsonHandler {
-	h := &JsonHandler{
-		fn:               reflectFunc(fn),
-		RequestValidator: Defaults,
-		ArgumentResolver: Defaults,
-	}
-
-	for _, opt := range opts {
-		opt(h)
-	}
-
-	return h
+	return defaultAPI.Wrap(fn, opts...)
 }
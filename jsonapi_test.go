@@ -14,6 +14,17 @@ import (
 //go:embed testdata
 var testdata embed.FS
 
+// testAPI returns an API for a test whose internal errors have a predictable reference,
+// as they are random, and are not logged
+func testAPI() *jsonapi.API {
+	return &jsonapi.API{
+		NewErrorReference: func() string {
+			return "f00dcafe"
+		},
+		LogInternalError: func(req *http.Request, ref string, err error) {},
+	}
+}
+
 func mustOpen(t *testing.T, name string) fs.File {
 	t.Helper()
 	f, err := testdata.Open(fmt.Sprintf("testdata/%s", name))
@@ -0,0 +1,123 @@
+package jsonapi
+
+import (
+	"log/slog"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+var loggerType = reflect.TypeOf((*slog.Logger)(nil))
+
+// LogLevels are the levels at which requests are logged, depending on their status code
+type LogLevels struct {
+	Success     slog.Level // For 1xx, 2xx and 3xx responses
+	ClientError slog.Level // For 4xx responses
+	ServerError slog.Level // For 5xx responses
+}
+
+// DefaultLogLevels are the levels used by handlers that do not set their own
+var DefaultLogLevels = LogLevels{
+	Success:     slog.LevelInfo,
+	ClientError: slog.LevelWarn,
+	ServerError: slog.LevelError,
+}
+
+func (l LogLevels) level(status int) slog.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return l.ServerError
+	case status >= http.StatusBadRequest:
+		return l.ClientError
+	default:
+		return l.Success
+	}
+}
+
+// WithLogger makes the handler log every request it serves.
+//
+// Requests are logged with their method, path, status, duration, the name of the
+// wrapped function and, for failed requests, the chain of the error. The error chain of
+// client errors is only logged when API.LogDomainErrors is set.
+func WithLogger(logger *slog.Logger) OptsFn {
+	return func(h *JsonHandler) {
+		h.Logger = logger
+	}
+}
+
+// WithLogLevels sets the levels at which the handler logs requests
+func WithLogLevels(levels LogLevels) OptsFn {
+	return func(h *JsonHandler) {
+		h.LogLevels = &levels
+	}
+}
+
+func (h *JsonHandler) logger() *slog.Logger {
+	if h.Logger != nil {
+		return h.Logger
+	}
+
+	return h.api.Logger
+}
+
+func (h *JsonHandler) logLevels() LogLevels {
+	if h.LogLevels != nil {
+		return *h.LogLevels
+	}
+
+	if h.api.LogLevels != nil {
+		return *h.api.LogLevels
+	}
+
+	return DefaultLogLevels
+}
+
+// requestLogger returns the logger for a request, with its attributes.
+//
+// When the handler has no logger, the default slog logger is used.
+func requestLogger(req *http.Request) *slog.Logger {
+	logger := slog.Default()
+	name := ""
+
+	if h := handlerFrom(req); h != nil {
+		if l := h.logger(); l != nil {
+			logger = l
+		}
+
+		name = h.name()
+	}
+
+	logger = logger.With(
+		slog.String("method", req.Method),
+		slog.String("path", req.URL.Path),
+		slog.String("handler", name),
+	)
+
+	if id := RequestIDFrom(req.Context()); id != "" {
+		logger = logger.With(slog.String("request_id", string(id)))
+	}
+
+	return logger
+}
+
+// logRequest logs a request once it has been served
+func (h *JsonHandler) logRequest(w *responseWriter, req *http.Request, start time.Time) {
+	status := w.statusCode()
+
+	attrs := []slog.Attr{
+		slog.Int("status", status),
+		slog.Duration("duration", time.Since(start)),
+	}
+
+	state := stateFrom(req)
+
+	if state.reference != "" {
+		attrs = append(attrs, slog.String("reference", state.reference))
+	}
+
+	if state.err != nil && (status >= http.StatusInternalServerError || h.api.logDomainErrors()) {
+		attrs = append(attrs, slog.Any("error", errorChain(state.err)))
+	}
+
+	requestLogger(req).LogAttrs(req.Context(), h.logLevels().level(status), "request", attrs...)
+}
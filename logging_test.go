@@ -0,0 +1,121 @@
+package jsonapi_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mnavarrocarter/jsonapi"
+)
+
+func findPlan(_ context.Context) map[string]string {
+	return map[string]string{"id": "1234"}
+}
+
+func updatePlan(_ context.Context) error {
+	return customErr(http.StatusConflict, errConflict, "plan cannot be updated")
+}
+
+func deletePlan(_ context.Context) error {
+	return errors.New("connection refused")
+}
+
+func auditPlan(logger *slog.Logger) {
+	logger.Info("audited")
+}
+
+// newTestLogger logs to buf, without the time and duration, so the lines are stable
+func newTestLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+
+			return a
+		},
+	}))
+}
+
+func TestWithLogger(t *testing.T) {
+	tt := []struct {
+		name            string
+		fn              interface{}
+		opts            []jsonapi.OptsFn
+		logDomainErrors bool
+		expectedLog     string
+	}{
+		{
+			name:        "success",
+			fn:          findPlan,
+			expectedLog: `level=INFO msg=request method=GET path=/plans/1234 handler=github.com/mnavarrocarter/jsonapi_test.findPlan status=200` + "\n",
+		},
+		{
+			name:        "client error",
+			fn:          updatePlan,
+			expectedLog: `level=WARN msg=request method=GET path=/plans/1234 handler=github.com/mnavarrocarter/jsonapi_test.updatePlan status=409` + "\n",
+		},
+		{
+			name:            "client error with domain errors",
+			fn:              updatePlan,
+			logDomainErrors: true,
+			expectedLog:     `level=WARN msg=request method=GET path=/plans/1234 handler=github.com/mnavarrocarter/jsonapi_test.updatePlan status=409 error="[plan cannot be updated plan already exists]"` + "\n",
+		},
+		{
+			name:        "server error",
+			fn:          deletePlan,
+			expectedLog: `level=ERROR msg=request method=GET path=/plans/1234 handler=github.com/mnavarrocarter/jsonapi_test.deletePlan status=500 reference=f00dcafe error="[connection refused]"` + "\n",
+		},
+		{
+			name: "custom levels",
+			fn:   findPlan,
+			opts: []jsonapi.OptsFn{jsonapi.WithLogLevels(jsonapi.LogLevels{
+				Success:     slog.LevelDebug,
+				ClientError: slog.LevelInfo,
+				ServerError: slog.LevelError,
+			})},
+			expectedLog: `level=DEBUG msg=request method=GET path=/plans/1234 handler=github.com/mnavarrocarter/jsonapi_test.findPlan status=200` + "\n",
+		},
+		{
+			name: "injected logger",
+			fn:   auditPlan,
+			expectedLog: `level=INFO msg=audited method=GET path=/plans/1234 handler=github.com/mnavarrocarter/jsonapi_test.auditPlan` + "\n" +
+				`level=INFO msg=request method=GET path=/plans/1234 handler=github.com/mnavarrocarter/jsonapi_test.auditPlan status=204` + "\n",
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+
+			api := testAPI()
+			api.LogDomainErrors = test.logDomainErrors
+
+			opts := append([]jsonapi.OptsFn{jsonapi.WithLogger(newTestLogger(buf))}, test.opts...)
+			handler := api.Wrap(test.fn, opts...)
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/plans/1234", http.NoBody))
+
+			if test.expectedLog != buf.String() {
+				t.Errorf("log does not match\nexpected: %s\nreceived: %s\n", test.expectedLog, buf.String())
+			}
+		})
+	}
+}
+
+func TestAPI_Logger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	api := &jsonapi.API{Logger: newTestLogger(buf)}
+
+	api.Wrap(findPlan).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/plans/1234", http.NoBody))
+
+	expected := `level=INFO msg=request method=GET path=/plans/1234 handler=github.com/mnavarrocarter/jsonapi_test.findPlan status=200` + "\n"
+	if expected != buf.String() {
+		t.Errorf("log does not match\nexpected: %s\nreceived: %s\n", expected, buf.String())
+	}
+}
@@ -0,0 +1,243 @@
+package jsonapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A Translator yields the message for an error code in a single language.
+//
+// Params are the parameters of the error, like {"min": 2} for a min_length error.
+type Translator interface {
+	Translate(code string, params map[string]interface{}) (string, bool)
+}
+
+// Messages is a Translator backed by a map of error codes to message templates.
+//
+// In the templates, {name} is replaced by the param with that name.
+type Messages map[string]string
+
+func (m Messages) Translate(code string, params map[string]interface{}) (string, bool) {
+	tpl, ok := m[code]
+	if !ok {
+		return "", false
+	}
+
+	if len(params) == 0 {
+		return tpl, true
+	}
+
+	pairs := make([]string, 0, len(params)*2)
+	for k, v := range params {
+		pairs = append(pairs, "{"+k+"}", fmt.Sprintf("%v", v))
+	}
+
+	return strings.NewReplacer(pairs...).Replace(tpl), true
+}
+
+// A MessageCatalog holds the translations of the messages of the package, by language.
+//
+// The language of a request is negotiated using the Accept-Language header. When none of the
+// accepted languages is in the catalog, the Fallback language is used.
+type MessageCatalog struct {
+	Fallback string
+
+	mu    sync.RWMutex
+	langs map[string][]Translator
+}
+
+// NewMessageCatalog makes an empty MessageCatalog
+func NewMessageCatalog(fallback string) *MessageCatalog {
+	return &MessageCatalog{
+		Fallback: fallback,
+		langs:    make(map[string][]Translator),
+	}
+}
+
+// Add adds translations for a language, like "es" or "es-CL".
+//
+// Translators added later take precedence over the ones added before, so you can
+// override some messages of a language.
+func (c *MessageCatalog) Add(lang string, t Translator) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lang = strings.ToLower(lang)
+	c.langs[lang] = append([]Translator{t}, c.langs[lang]...)
+}
+
+// Message yields the message for an error code in the language negotiated from an
+// Accept-Language header.
+func (c *MessageCatalog) Message(acceptLanguage, code string, params map[string]interface{}) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, lang := range append(parseAcceptLanguage(acceptLanguage), strings.ToLower(c.Fallback)) {
+		for lang != "" {
+			for _, t := range c.langs[lang] {
+				if msg, ok := t.Translate(code, params); ok {
+					return msg, true
+				}
+			}
+
+			// We try with the parent language, so "es-cl" falls back to "es"
+			i := strings.LastIndex(lang, "-")
+			if i < 0 {
+				break
+			}
+
+			lang = lang[:i]
+		}
+	}
+
+	return "", false
+}
+
+// Catalog is the MessageCatalog used for the messages of the package, unless the API of
+// the handler has its own. See API.Catalog.
+//
+// It comes with the messages in english. Add your own translations to it.
+var Catalog = NewMessageCatalog("en")
+
+func init() {
+	Catalog.Add("en", Messages{
+		// Messages of the handlers
+		"empty_body":          "Request body cannot be empty",
+		"invalid_body":        "Error while validating the request",
+		"validation_error":    "There was an error while validating the request",
+		"validation_failed":   "Validation errors",
+		"argument_resolution": "Error while trying to resolve handler arguments",
+		"unexpected_error":    "An unexpected error has occurred",
+		"encoding_error":      "There was an error while encoding the response",
+		"timeout":             "The request took too long to be served",
+		"rate_limited":        "Too many requests, try again in {retry_after} seconds",
+		"not_found":           "No handler found for {method} {path}",
+		"method_not_allowed":  "Method not allowed for {method} {path}",
+
+		// Messages of idempotent requests
+		"idempotency_in_flight":  "A request with the same idempotency key is being processed",
+		"idempotency_key_reused": "The idempotency key has already been used for a different request",
+
+		// Messages of conditional requests
+		"precondition_failed":   "The resource has been modified since it was read",
+		"precondition_required": "The request must include an If-Match header",
+
+		// Messages of the lists
+		"invalid_cursor":   "The cursor is not valid",
+		"unknown_filter":   "Unknown filter, it must be one of: {allowed}",
+		"unknown_operator": "Unknown operator {operator}, it must be one of: {allowed}",
+		"unknown_sort":     "Unknown sort field, it must be one of: {allowed}",
+		"unknown_field":    "Unknown field {path}",
+
+		// Messages of the validation errors
+		"required":              "{property} is required",
+		"type":                  "Invalid type. Expected: {expected}, given: {given}",
+		"enum":                  "{field} must be one of the following: {allowed}",
+		"const":                 "{field} does not match: {allowed}",
+		"format":                "Does not match format '{format}'",
+		"pattern":               "Does not match pattern '{pattern}'",
+		"min_length":            "String length must be greater than or equal to {min}",
+		"max_length":            "String length must be less than or equal to {max}",
+		"minimum":               "Must be greater than or equal to {min}",
+		"exclusive_minimum":     "Must be greater than {min}",
+		"maximum":               "Must be less than or equal to {max}",
+		"exclusive_maximum":     "Must be less than {max}",
+		"multiple_of":           "Must be a multiple of {multiple}",
+		"min_items":             "Array must have at least {min} items",
+		"max_items":             "Array must have at most {max} items",
+		"min_properties":        "Must have at least {min} properties",
+		"max_properties":        "Must have at most {max} properties",
+		"additional_properties": "Additional property {property} is not allowed",
+	})
+}
+
+// translate yields the message for code in the language of the request, or def if there is none
+func translate(req *http.Request, code string, params map[string]interface{}, def string) string {
+	if code == "" || req == nil {
+		return def
+	}
+
+	msg, ok := apiFrom(req).catalog().Message(req.Header.Get("Accept-Language"), code, params)
+	if !ok {
+		return def
+	}
+
+	return msg
+}
+
+// translateItems returns copies of validation errors with their messages translated. The
+// items are left as they are, as they may be shared, like the items of a validator.
+func translateItems(req *http.Request, items []*ErrorItem) []*ErrorItem {
+	translated := make([]*ErrorItem, 0, len(items))
+
+	for _, item := range items {
+		params := make(map[string]interface{}, len(item.Params)+1)
+		for k, v := range item.Params {
+			params[k] = v
+		}
+
+		params["field"] = item.Field
+
+		c := *item
+		c.Msg = translate(req, item.Code, params, item.Msg)
+		translated = append(translated, &c)
+	}
+
+	return translated
+}
+
+// parseAcceptLanguage parses an Accept-Language header into a list of lowercase language
+// tags, ordered by preference.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+
+	for _, part := range strings.Split(header, ",") {
+		tag, params := part, ""
+		if i := strings.Index(part, ";"); i >= 0 {
+			tag, params = part[:i], strings.TrimSpace(part[i+1:])
+		}
+
+		tag = strings.ToLower(strings.TrimSpace(tag))
+
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+
+		if strings.HasPrefix(params, "q=") {
+			f, err := strconv.ParseFloat(params[2:], 64)
+			if err != nil {
+				continue
+			}
+
+			q = f
+		}
+
+		if q <= 0 {
+			continue
+		}
+
+		tags = append(tags, weighted{tag: tag, q: q})
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	langs := make([]string, 0, len(tags))
+	for _, t := range tags {
+		langs = append(langs, t.tag)
+	}
+
+	return langs
+}
@@ -0,0 +1,145 @@
+package jsonapi_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mnavarrocarter/jsonapi"
+)
+
+// newCatalogAPI returns an API with messages in spanish
+func newCatalogAPI() *jsonapi.API {
+	catalog := jsonapi.NewMessageCatalog("en")
+	catalog.Add("es", jsonapi.Messages{
+		"empty_body":        "El cuerpo de la petición no puede estar vacío",
+		"validation_failed": "Errores de validación",
+		"invalid_body":      "El cuerpo de la petición no es un json válido",
+		"not_found":         "No hay un handler para {method} {path}",
+		"min_length":        "El largo debe ser mayor o igual a {min}",
+	})
+
+	catalog.Add("es-CL", jsonapi.Messages{
+		"format": "No calza con el formato '{format}'",
+	})
+
+	return &jsonapi.API{Catalog: catalog}
+}
+
+func TestCatalog(t *testing.T) {
+	api := newCatalogAPI()
+
+	tt := []struct {
+		name             string
+		handler          http.Handler
+		req              *http.Request
+		acceptLanguage   string
+		expectedResponse []byte
+	}{
+		{
+			name:             "translated message",
+			handler:          api.Wrap(func(cmd *testCmd) {}),
+			req:              httptest.NewRequest(http.MethodPost, "/", http.NoBody),
+			acceptLanguage:   "es",
+			expectedResponse: []byte(`{"status":400,"kind":"Invalid Request","code":"empty_body","details":"El cuerpo de la petición no puede estar vacío"}` + "\n"),
+		},
+		{
+			name:             "falls back to the parent language",
+			handler:          api.Wrap(func(cmd *testCmd) {}),
+			req:              httptest.NewRequest(http.MethodPost, "/", http.NoBody),
+			acceptLanguage:   "es-AR",
+			expectedResponse: []byte(`{"status":400,"kind":"Invalid Request","code":"empty_body","details":"El cuerpo de la petición no puede estar vacío"}` + "\n"),
+		},
+		{
+			name:             "prefers the language with the highest quality",
+			handler:          api.Wrap(func(cmd *testCmd) {}),
+			req:              httptest.NewRequest(http.MethodPost, "/", http.NoBody),
+			acceptLanguage:   "en;q=0.5, es;q=0.9",
+			expectedResponse: []byte(`{"status":400,"kind":"Invalid Request","code":"empty_body","details":"El cuerpo de la petición no puede estar vacío"}` + "\n"),
+		},
+		{
+			name:             "falls back to english",
+			handler:          api.Wrap(func(cmd *testCmd) {}),
+			req:              httptest.NewRequest(http.MethodPost, "/", http.NoBody),
+			acceptLanguage:   "de, fr;q=0.5",
+			expectedResponse: []byte(`{"status":400,"kind":"Invalid Request","code":"empty_body","details":"Request body cannot be empty"}` + "\n"),
+		},
+		{
+			name:             "translated malformed body",
+			handler:          api.Wrap(func(cmd *testCmd) {}),
+			req:              httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{bad`)),
+			acceptLanguage:   "es",
+			expectedResponse: []byte(`{"status":400,"kind":"Invalid Request","code":"invalid_body","details":"El cuerpo de la petición no es un json válido"}` + "\n"),
+		},
+		{
+			name:             "handler of another API",
+			handler:          jsonapi.Wrap(func(cmd *testCmd) {}),
+			req:              httptest.NewRequest(http.MethodPost, "/", http.NoBody),
+			acceptLanguage:   "es",
+			expectedResponse: []byte(`{"status":400,"kind":"Invalid Request","code":"empty_body","details":"Request body cannot be empty"}` + "\n"),
+		},
+		{
+			name:             "translated static handler",
+			handler:          api.NotFoundHandler(),
+			req:              httptest.NewRequest(http.MethodGet, "/user", http.NoBody),
+			acceptLanguage:   "es",
+			expectedResponse: []byte(`{"status":404,"kind":"Not Found","code":"not_found","details":"No hay un handler para GET /user"}` + "\n"),
+		},
+		{
+			name:             "translated validation errors",
+			handler:          api.Wrap(func(cmd *testCmd) {}, jsonapi.WithSchema(mustOpen(t, "schema.json"))),
+			req:              httptest.NewRequest(http.MethodPost, "/", mustOpen(t, "invalid.json")),
+			acceptLanguage:   "es-CL",
+			expectedResponse: []byte(`{"status":400,"kind":"Invalid Request","code":"validation_failed","details":"Errores de validación","errors":[{"field":"id","pointer":"/id","source":"body","code":"format","params":{"format":"uuid"},"value":"3f2476fd2b-270f-4baa-81c9-01e91fc87fd3","msg":"No calza con el formato 'uuid'"},{"field":"name","pointer":"/name","source":"body","code":"min_length","params":{"min":1},"value":"","msg":"El largo debe ser mayor o igual a 1"}]}` + "\n"),
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			test.req.Header.Set("Accept-Language", test.acceptLanguage)
+
+			test.handler.ServeHTTP(rec, test.req)
+
+			if !bytes.Equal(test.expectedResponse, rec.Body.Bytes()) {
+				t.Errorf(
+					"response body does not match\nexpected: %s\nreceived: %s\n",
+					string(test.expectedResponse),
+					rec.Body.String(),
+				)
+			}
+		})
+	}
+}
+
+func TestCatalog_SharedItems(t *testing.T) {
+	// The validator reports the same items to every request
+	items := []*jsonapi.ErrorItem{{Field: "name", Code: "min_length", Params: map[string]interface{}{"min": 1}, Msg: "Too short"}}
+
+	handler := newCatalogAPI().Wrap(func() {}, jsonapi.WithValidator(jsonapi.ValidatorFunc(func(req *http.Request) ([]*jsonapi.ErrorItem, error) {
+		return items, nil
+	})))
+
+	for _, test := range []struct {
+		acceptLanguage string
+		msg            string
+	}{
+		{acceptLanguage: "es", msg: "El largo debe ser mayor o igual a 1"},
+		{acceptLanguage: "de", msg: "Too short"},
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		req.Header.Set("Accept-Language", test.acceptLanguage)
+		handler.ServeHTTP(rec, req)
+
+		if !bytes.Contains(rec.Body.Bytes(), []byte(`"msg":"`+test.msg+`"`)) {
+			t.Errorf("expected the message %q in %s", test.msg, rec.Body.String())
+		}
+	}
+
+	if items[0].Msg != "Too short" {
+		t.Errorf("expected the items of the validator to be left as they are, got %q", items[0].Msg)
+	}
+}
@@ -0,0 +1,203 @@
+package jsonapi
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the latency histogram buckets used
+// by NewMetrics.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics collects the metrics of the handlers it is given to, and exposes them in the
+// Prometheus text format. See WithMetrics.
+//
+// For each handler, labelled by its name, it records:
+//
+//	jsonapi_requests_total             Requests served, by status code
+//	jsonapi_request_duration_seconds   Histogram of the latency of the requests
+//	jsonapi_requests_in_flight         Requests being served
+//	jsonapi_validation_failures_total  Requests that did not pass validation
+//	jsonapi_panics_total               Panics recovered
+//
+// A Metrics is an http.Handler that serves the metrics, to be scraped by Prometheus.
+type Metrics struct {
+	mu       sync.Mutex
+	buckets  []float64
+	handlers map[string]*handlerMetrics
+}
+
+type handlerMetrics struct {
+	requests           map[int]uint64 // By status code
+	buckets            []uint64       // Not cumulative, the last one is +Inf
+	sum                float64
+	count              uint64
+	inFlight           int64
+	validationFailures uint64
+	panics             uint64
+}
+
+// NewMetrics makes a Metrics. When no buckets are given, DefaultBuckets are used.
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &Metrics{
+		buckets:  buckets,
+		handlers: make(map[string]*handlerMetrics),
+	}
+}
+
+// WithMetrics makes the handler record its metrics in m
+func WithMetrics(m *Metrics) OptsFn {
+	return func(h *JsonHandler) {
+		h.Metrics = m
+	}
+}
+
+func (h *JsonHandler) metrics() *Metrics {
+	if h.Metrics != nil {
+		return h.Metrics
+	}
+
+	return h.api.Metrics
+}
+
+// handler returns the metrics of a handler. It must be called with the lock held.
+func (m *Metrics) handler(name string) *handlerMetrics {
+	hm, ok := m.handlers[name]
+	if !ok {
+		hm = &handlerMetrics{
+			requests: make(map[int]uint64),
+			buckets:  make([]uint64, len(m.buckets)+1),
+		}
+		m.handlers[name] = hm
+	}
+
+	return hm
+}
+
+func (m *Metrics) requestStarted(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.handler(name).inFlight++
+}
+
+func (m *Metrics) requestServed(name string, status int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hm := m.handler(name)
+	hm.inFlight--
+	hm.requests[status]++
+
+	seconds := duration.Seconds()
+	hm.buckets[sort.SearchFloat64s(m.buckets, seconds)]++
+	hm.sum += seconds
+	hm.count++
+}
+
+func (m *Metrics) validationFailed(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.handler(name).validationFailures++
+}
+
+func (m *Metrics) panicked(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.handler(name).panics++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	bw := bufio.NewWriter(w)
+	m.write(bw)
+	_ = bw.Flush()
+}
+
+func (m *Metrics) write(w *bufio.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.handlers))
+	for name := range m.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	header(w, "jsonapi_requests_total", "counter", "Requests served, by status code.")
+	for _, name := range names {
+		hm := m.handlers[name]
+
+		codes := make([]int, 0, len(hm.requests))
+		for code := range hm.requests {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+
+		for _, code := range codes {
+			_, _ = fmt.Fprintf(w, "jsonapi_requests_total{handler=\"%s\",code=\"%d\"} %d\n", escapeLabel(name), code, hm.requests[code])
+		}
+	}
+
+	header(w, "jsonapi_request_duration_seconds", "histogram", "Latency of the requests.")
+	for _, name := range names {
+		hm := m.handlers[name]
+		label := escapeLabel(name)
+
+		var cumulative uint64
+		for i, le := range m.buckets {
+			cumulative += hm.buckets[i]
+			_, _ = fmt.Fprintf(w, "jsonapi_request_duration_seconds_bucket{handler=\"%s\",le=\"%s\"} %d\n", label, formatFloat(le), cumulative)
+		}
+
+		_, _ = fmt.Fprintf(w, "jsonapi_request_duration_seconds_bucket{handler=\"%s\",le=\"+Inf\"} %d\n", label, hm.count)
+		_, _ = fmt.Fprintf(w, "jsonapi_request_duration_seconds_sum{handler=\"%s\"} %s\n", label, formatFloat(hm.sum))
+		_, _ = fmt.Fprintf(w, "jsonapi_request_duration_seconds_count{handler=\"%s\"} %d\n", label, hm.count)
+	}
+
+	header(w, "jsonapi_requests_in_flight", "gauge", "Requests being served.")
+	for _, name := range names {
+		_, _ = fmt.Fprintf(w, "jsonapi_requests_in_flight{handler=\"%s\"} %d\n", escapeLabel(name), m.handlers[name].inFlight)
+	}
+
+	header(w, "jsonapi_validation_failures_total", "counter", "Requests that did not pass validation.")
+	for _, name := range names {
+		_, _ = fmt.Fprintf(w, "jsonapi_validation_failures_total{handler=\"%s\"} %d\n", escapeLabel(name), m.handlers[name].validationFailures)
+	}
+
+	header(w, "jsonapi_panics_total", "counter", "Panics recovered.")
+	for _, name := range names {
+		_, _ = fmt.Fprintf(w, "jsonapi_panics_total{handler=\"%s\"} %d\n", escapeLabel(name), m.handlers[name].panics)
+	}
+}
+
+func header(w *bufio.Writer, name, typ, help string) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,118 @@
+package jsonapi_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mnavarrocarter/jsonapi"
+)
+
+func TestWithMetrics(t *testing.T) {
+	m := jsonapi.NewMetrics(60)
+
+	failValidation := jsonapi.WithValidator(jsonapi.ValidatorFunc(func(req *http.Request) ([]*jsonapi.ErrorItem, error) {
+		if req.URL.Query().Get("invalid") == "" {
+			return nil, nil
+		}
+
+		return []*jsonapi.ErrorItem{{Field: "name", Msg: "name is required"}}, nil
+	}))
+
+	plans := jsonapi.Wrap(func() error {
+		return nil
+	}, jsonapi.WithName("plans"), jsonapi.WithMetrics(m), failValidation)
+
+	conflicts := jsonapi.Wrap(func() error {
+		return errConflict
+	}, jsonapi.WithName(`plan "conflicts"`), jsonapi.WithMetrics(m), jsonapi.WithErrorMapping(conflictMapping))
+
+	panics := (&jsonapi.API{Metrics: m}).Wrap(func() {
+		panic("something really bad has happened")
+	}, jsonapi.WithName("panics"))
+
+	for _, req := range []struct {
+		handler http.Handler
+		url     string
+	}{
+		{handler: plans, url: "/plans"},
+		{handler: plans, url: "/plans"},
+		{handler: plans, url: "/plans?invalid=1"},
+		{handler: conflicts, url: "/plans"},
+		{handler: panics, url: "/plans"},
+	} {
+		req.handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, req.url, http.NoBody))
+	}
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+
+	// The sums of the latencies cannot be known in advance
+	var lines []string
+	for _, line := range strings.SplitAfter(rec.Body.String(), "\n") {
+		if !strings.HasPrefix(line, "jsonapi_request_duration_seconds_sum") {
+			lines = append(lines, line)
+		}
+	}
+
+	expected := []byte(`# HELP jsonapi_requests_total Requests served, by status code.
+# TYPE jsonapi_requests_total counter
+jsonapi_requests_total{handler="panics",code="500"} 1
+jsonapi_requests_total{handler="plan \"conflicts\"",code="409"} 1
+jsonapi_requests_total{handler="plans",code="204"} 2
+jsonapi_requests_total{handler="plans",code="400"} 1
+# HELP jsonapi_request_duration_seconds Latency of the requests.
+# TYPE jsonapi_request_duration_seconds histogram
+jsonapi_request_duration_seconds_bucket{handler="panics",le="60"} 1
+jsonapi_request_duration_seconds_bucket{handler="panics",le="+Inf"} 1
+jsonapi_request_duration_seconds_count{handler="panics"} 1
+jsonapi_request_duration_seconds_bucket{handler="plan \"conflicts\"",le="60"} 1
+jsonapi_request_duration_seconds_bucket{handler="plan \"conflicts\"",le="+Inf"} 1
+jsonapi_request_duration_seconds_count{handler="plan \"conflicts\""} 1
+jsonapi_request_duration_seconds_bucket{handler="plans",le="60"} 3
+jsonapi_request_duration_seconds_bucket{handler="plans",le="+Inf"} 3
+jsonapi_request_duration_seconds_count{handler="plans"} 3
+# HELP jsonapi_requests_in_flight Requests being served.
+# TYPE jsonapi_requests_in_flight gauge
+jsonapi_requests_in_flight{handler="panics"} 0
+jsonapi_requests_in_flight{handler="plan \"conflicts\""} 0
+jsonapi_requests_in_flight{handler="plans"} 0
+# HELP jsonapi_validation_failures_total Requests that did not pass validation.
+# TYPE jsonapi_validation_failures_total counter
+jsonapi_validation_failures_total{handler="panics"} 0
+jsonapi_validation_failures_total{handler="plan \"conflicts\""} 0
+jsonapi_validation_failures_total{handler="plans"} 1
+# HELP jsonapi_panics_total Panics recovered.
+# TYPE jsonapi_panics_total counter
+jsonapi_panics_total{handler="panics"} 1
+jsonapi_panics_total{handler="plan \"conflicts\""} 0
+jsonapi_panics_total{handler="plans"} 0
+`)
+
+	if received := []byte(strings.Join(lines, "")); !bytes.Equal(expected, received) {
+		t.Errorf("metrics do not match\nexpected:\n%s\nreceived:\n%s\n", expected, received)
+	}
+}
+
+func TestWithMetrics_InFlight(t *testing.T) {
+	m := jsonapi.NewMetrics()
+	scraped := &bytes.Buffer{}
+
+	handler := jsonapi.Wrap(func() {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+		scraped.Write(rec.Body.Bytes())
+	}, jsonapi.WithName("slow"), jsonapi.WithMetrics(m))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", http.NoBody))
+
+	if !strings.Contains(scraped.String(), `jsonapi_requests_in_flight{handler="slow"} 1`+"\n") {
+		t.Errorf("expected a request in flight, got:\n%s", scraped.String())
+	}
+}
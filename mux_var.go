@@ -6,6 +6,8 @@ import (
 	"reflect"
 )
 
+// VarFunc yields the route vars of a request. Set it to the function of your router,
+// like mux.Vars, or set it in an API.
 var VarFunc = func(r *http.Request) map[string]string {
 	return map[string]string{}
 }
@@ -14,6 +16,7 @@ func WithVar(key string, pos int) OptsFn {
 	return func(h *JsonHandler) {
 		h.ArgumentResolver = &varInjector{
 			next: h.ArgumentResolver,
+			api:  h.api,
 			key:  key,
 			pos:  pos,
 		}
@@ -26,6 +29,7 @@ func WithVar(key string, pos int) OptsFn {
 // that should be injected in that position.
 type varInjector struct {
 	next ArgumentResolver
+	api  *API
 	key  string
 	pos  int
 }
@@ -35,7 +39,7 @@ func (vi *varInjector) Resolve(req *http.Request, t reflect.Type, pos int) (refl
 		return vi.next.Resolve(req, t, pos)
 	}
 
-	vars := VarFunc(req)
+	vars := vi.api.vars(req)
 
 	val, ok := vars[vi.key]
 	if !ok {
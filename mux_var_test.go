@@ -34,14 +34,14 @@ func Test_WithVar(t *testing.T) {
 			caseName:         "not found",
 			handler:          jsonapi.NotFoundHandler,
 			req:              httptest.NewRequest("GET", "/user", http.NoBody),
-			expectedResponse: []byte(`{"status":404,"details":"No handler found for GET /user"}` + "\n"),
+			expectedResponse: []byte(`{"status":404,"kind":"Not Found","code":"not_found","details":"No handler found for GET /user"}` + "\n"),
 			expectedStatus:   http.StatusNotFound,
 		},
 		{
 			caseName:         "method not allowed",
 			handler:          jsonapi.MethodNotAllowedHandler,
 			req:              httptest.NewRequest("POST", "/user/1234", http.NoBody),
-			expectedResponse: []byte(`{"status":405,"details":"Method not allowed for POST /user/1234"}` + "\n"),
+			expectedResponse: []byte(`{"status":405,"kind":"Method Not Allowed","code":"method_not_allowed","details":"Method not allowed for POST /user/1234"}` + "\n"),
 			expectedStatus:   http.StatusMethodNotAllowed,
 		},
 	}
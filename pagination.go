@@ -0,0 +1,328 @@
+package jsonapi
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The page limits of the handlers that have none. See WithPageLimits.
+const (
+	DefaultPageLimit    = 20
+	DefaultMaxPageLimit = 100
+)
+
+// CursorKey signs the cursors of the pages, so clients cannot tamper with them. It is
+// random by default, so cursors are only valid in the process that made them: set it,
+// or API.CursorKey, when the requests of a client can be served by several instances.
+var CursorKey = func() []byte {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+
+	return b
+}()
+
+// A PageRequest is the page of a list a request asks for, from the limit, offset and cursor
+// parameters of its query string, like "?limit=20&offset=40".
+//
+// Functions can take it as an argument. Requests with a limit above the maximum of the
+// handler, a negative offset or a cursor that was not made by the handler get a 400
+// error. See WithPageLimits.
+type PageRequest struct {
+	Limit  int    // The maximum number of items of the page
+	Offset int    // The number of items to skip, for offset pagination
+	After  string // The page starts after this position, for cursor pagination. See Page.Next
+	Before string // The page ends before this position, for cursor pagination. See Page.Prev
+}
+
+var pageRequestType = reflect.TypeOf(PageRequest{})
+
+// A Page is a page of a list, returned by functions to send the items along with the page
+// metadata and the Link headers of the next, previous and first pages:
+//
+//	{"items": [...], "meta": {"limit": 20, "offset": 40, "total": 95}}
+//
+// With offset pagination, the links are computed from the PageRequest and the Total.
+// With cursor pagination, functions set Next and Prev to the positions the next and
+// previous pages start after and end before, like the ID of the last and first items,
+// which the clients get as opaque cursors.
+type Page[T any] struct {
+	Items []T
+	Total int    // The number of items of the list, if known. When zero, there is a next page if this one is full
+	Next  string // The position of the last item, if there is a next page
+	Prev  string // The position of the first item, if there is a previous page
+}
+
+func (p Page[T]) page() pageData {
+	items := p.Items
+	if items == nil {
+		items = []T{}
+	}
+
+	return pageData{items: items, count: len(p.Items), total: p.Total, next: p.Next, prev: p.Prev}
+}
+
+// pager is implemented by all the Page types
+type pager interface {
+	page() pageData
+}
+
+type pageData struct {
+	items      interface{}
+	count      int
+	total      int
+	next, prev string
+}
+
+type pageResponse struct {
+	Items interface{} `json:"items"`
+	Meta  pageMeta    `json:"meta"`
+}
+
+type pageMeta struct {
+	Limit      int    `json:"limit"`
+	Offset     *int   `json:"offset,omitempty"`
+	Total      int    `json:"total,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// WithPageLimits sets the limit of the pages of the handler when the request has none,
+// and the maximum limit requests can ask for.
+func WithPageLimits(limit, max int) OptsFn {
+	return func(h *JsonHandler) {
+		h.PageLimit = limit
+		h.MaxPageLimit = max
+	}
+}
+
+// pageLimits returns the default and maximum limits of the pages of a request
+func pageLimits(req *http.Request) (int, int) {
+	limit, max := DefaultPageLimit, DefaultMaxPageLimit
+
+	if h := handlerFrom(req); h != nil {
+		if h.PageLimit > 0 {
+			limit = h.PageLimit
+		}
+
+		if h.MaxPageLimit > 0 {
+			max = h.MaxPageLimit
+		}
+	}
+
+	if limit > max {
+		limit = max
+	}
+
+	return limit, max
+}
+
+// parsePageRequest parses the page a request asks for. Invalid parameters are reported
+// as ErrorItems, and left to their defaults.
+func parsePageRequest(req *http.Request) (PageRequest, []*ErrorItem) {
+	limit, max := pageLimits(req)
+	page := PageRequest{Limit: limit}
+	query := req.URL.Query()
+
+	var items []*ErrorItem
+
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+
+		switch {
+		case err != nil:
+			items = append(items, queryItem("limit", v, "type", map[string]interface{}{"expected": "integer", "given": "string"}))
+		case n < 1:
+			items = append(items, queryItem("limit", n, "minimum", map[string]interface{}{"min": 1}))
+		case n > max:
+			items = append(items, queryItem("limit", n, "maximum", map[string]interface{}{"max": max}))
+		default:
+			page.Limit = n
+		}
+	}
+
+	if v := query.Get("cursor"); v != "" {
+		dir, pos, ok := openCursor(req, v)
+
+		switch {
+		case !ok:
+			items = append(items, queryItem("cursor", v, "invalid_cursor", nil))
+		case dir == "a":
+			page.After = pos
+		default:
+			page.Before = pos
+		}
+
+		return page, items
+	}
+
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+
+		switch {
+		case err != nil:
+			items = append(items, queryItem("offset", v, "type", map[string]interface{}{"expected": "integer", "given": "string"}))
+		case n < 0:
+			items = append(items, queryItem("offset", n, "minimum", map[string]interface{}{"min": 0}))
+		default:
+			page.Offset = n
+		}
+	}
+
+	return page, items
+}
+
+func queryItem(field string, value interface{}, code string, params map[string]interface{}) *ErrorItem {
+	return &ErrorItem{
+		Field:   field,
+		Pointer: JSONPointer(field),
+		Source:  SourceQuery,
+		Code:    code,
+		Params:  params,
+		Value:   value,
+		Msg:     "Invalid " + field,
+	}
+}
+
+// resolvePageRequest resolves the PageRequest argument of a function
+func resolvePageRequest(req *http.Request) (reflect.Value, error) {
+	page, items := parsePageRequest(req)
+	if len(items) != 0 {
+		return nilValue, &ValidationError{Items: items}
+	}
+
+	return reflect.ValueOf(page), nil
+}
+
+// renderPage renders a page as the items and its metadata, and sets the Link header of
+// the response to the next, previous and first pages.
+func renderPage(w http.ResponseWriter, req *http.Request, p pager) *pageResponse {
+	data := p.page()
+	page, _ := parsePageRequest(req)
+	resp := &pageResponse{Items: data.items, Meta: pageMeta{Limit: page.Limit, Total: data.total}}
+
+	var links []string
+	link := func(rel string, params map[string]string) {
+		links = append(links, "<"+pageURL(req, params)+`>; rel="`+rel+`"`)
+	}
+
+	cursors := req.URL.Query().Get("cursor") != "" || data.next != "" || data.prev != ""
+
+	if cursors {
+		if data.next != "" {
+			resp.Meta.NextCursor = sealCursor(req, "a", data.next)
+			link("next", map[string]string{"cursor": resp.Meta.NextCursor})
+		}
+
+		if data.prev != "" {
+			resp.Meta.PrevCursor = sealCursor(req, "b", data.prev)
+			link("prev", map[string]string{"cursor": resp.Meta.PrevCursor})
+		}
+
+		link("first", nil)
+	} else {
+		resp.Meta.Offset = &page.Offset
+
+		next := data.count == page.Limit
+		if data.total > 0 {
+			next = page.Offset+data.count < data.total
+		}
+
+		if next {
+			link("next", map[string]string{"offset": strconv.Itoa(page.Offset + page.Limit)})
+		}
+
+		if page.Offset > 0 {
+			prev := page.Offset - page.Limit
+			if prev < 0 {
+				prev = 0
+			}
+
+			link("prev", map[string]string{"offset": strconv.Itoa(prev)})
+		}
+
+		link("first", nil)
+
+		if data.total > 0 {
+			link("last", map[string]string{"offset": strconv.Itoa((data.total - 1) / page.Limit * page.Limit)})
+		}
+	}
+
+	w.Header().Set("Link", strings.Join(links, ", "))
+
+	return resp
+}
+
+// pageURL returns the URL of the request for another page
+func pageURL(req *http.Request, params map[string]string) string {
+	query := req.URL.Query()
+	query.Del("cursor")
+	query.Del("offset")
+
+	for k, v := range params {
+		query.Set(k, v)
+	}
+
+	u := url.URL{Path: req.URL.Path, RawQuery: query.Encode()}
+
+	return u.String()
+}
+
+// sealCursor makes the opaque cursor of a position, in a direction: "a" for after and "b"
+// for before. Cursors are signed for the handler of the request, so they cannot be
+// tampered with or used with other handlers.
+func sealCursor(req *http.Request, dir, pos string) string {
+	payload := dir + pos
+
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(cursorMAC(req, payload))
+}
+
+// openCursor verifies a cursor, and returns its direction and position
+func openCursor(req *http.Request, cursor string) (string, string, bool) {
+	encoded, sig, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return "", "", false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(payload) == 0 {
+		return "", "", false
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, cursorMAC(req, string(payload))) {
+		return "", "", false
+	}
+
+	dir := string(payload[:1])
+	if dir != "a" && dir != "b" {
+		return "", "", false
+	}
+
+	return dir, string(payload[1:]), true
+}
+
+func cursorMAC(req *http.Request, payload string) []byte {
+	key := CursorKey
+	if a := apiFrom(req); a.CursorKey != nil {
+		key = a.CursorKey
+	}
+
+	scope := ""
+	if h := handlerFrom(req); h != nil {
+		scope = h.name()
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(scope))
+	mac.Write([]byte{0})
+	mac.Write([]byte(payload))
+
+	return mac.Sum(nil)[:16]
+}
@@ -0,0 +1,159 @@
+package jsonapi_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mnavarrocarter/jsonapi"
+)
+
+var planNames = []string{"bronze", "silver", "gold", "platinum", "diamond"}
+
+func listPlans(page jsonapi.PageRequest) jsonapi.Page[string] {
+	end := page.Offset + page.Limit
+	if end > len(planNames) {
+		end = len(planNames)
+	}
+
+	return jsonapi.Page[string]{Items: planNames[page.Offset:end], Total: len(planNames)}
+}
+
+// scrollPlans pages the plans by cursor, using their names as positions
+func scrollPlans(page jsonapi.PageRequest) *jsonapi.Page[string] {
+	start := 0
+	for i, name := range planNames {
+		if name == page.After {
+			start = i + 1
+		}
+	}
+
+	end := start + page.Limit
+	if end > len(planNames) {
+		end = len(planNames)
+	}
+
+	p := &jsonapi.Page[string]{Items: planNames[start:end]}
+
+	if end < len(planNames) {
+		p.Next = planNames[end-1]
+	}
+
+	if start > 0 {
+		p.Prev = planNames[start]
+	}
+
+	return p
+}
+
+func TestPage_Offset(t *testing.T) {
+	handler := jsonapi.Wrap(listPlans, jsonapi.WithPageLimits(2, 3))
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+		body   string
+		link   string
+	}{
+		{
+			name:   "first page",
+			target: "/plans",
+			status: http.StatusOK,
+			body:   `{"items":["bronze","silver"],"meta":{"limit":2,"offset":0,"total":5}}` + "\n",
+			link:   `</plans?offset=2>; rel="next", </plans>; rel="first", </plans?offset=4>; rel="last"`,
+		},
+		{
+			name:   "middle page",
+			target: "/plans?limit=3&offset=1&sort=name",
+			status: http.StatusOK,
+			body:   `{"items":["silver","gold","platinum"],"meta":{"limit":3,"offset":1,"total":5}}` + "\n",
+			link:   `</plans?limit=3&offset=4&sort=name>; rel="next", </plans?limit=3&offset=0&sort=name>; rel="prev", </plans?limit=3&sort=name>; rel="first", </plans?limit=3&offset=3&sort=name>; rel="last"`,
+		},
+		{
+			name:   "last page",
+			target: "/plans?offset=4",
+			status: http.StatusOK,
+			body:   `{"items":["diamond"],"meta":{"limit":2,"offset":4,"total":5}}` + "\n",
+			link:   `</plans?offset=2>; rel="prev", </plans>; rel="first", </plans?offset=4>; rel="last"`,
+		},
+		{
+			name:   "limit above the maximum",
+			target: "/plans?limit=50&offset=-1",
+			status: http.StatusBadRequest,
+			body:   `{"status":400,"kind":"Invalid Request","code":"validation_failed","details":"Validation errors","errors":[{"field":"limit","pointer":"/limit","source":"query","code":"maximum","params":{"max":3},"value":50,"msg":"Must be less than or equal to 3"},{"field":"offset","pointer":"/offset","source":"query","code":"minimum","params":{"min":0},"value":-1,"msg":"Must be greater than or equal to 0"}]}` + "\n",
+		},
+		{
+			name:   "limit that is not a number",
+			target: "/plans?limit=all",
+			status: http.StatusBadRequest,
+			body:   `{"status":400,"kind":"Invalid Request","code":"validation_failed","details":"Validation errors","errors":[{"field":"limit","pointer":"/limit","source":"query","code":"type","params":{"expected":"integer","given":"string"},"value":"all","msg":"Invalid type. Expected: integer, given: string"}]}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.target, http.NoBody))
+
+			if test.status != rec.Code {
+				t.Errorf("expected status %d does not match received %d", test.status, rec.Code)
+			}
+
+			if test.body != rec.Body.String() {
+				t.Errorf("response body does not match\nexpected: %s\nreceived: %s\n", test.body, rec.Body.String())
+			}
+
+			if link := rec.Header().Get("Link"); test.link != link {
+				t.Errorf("link header does not match\nexpected: %s\nreceived: %s\n", test.link, link)
+			}
+		})
+	}
+}
+
+func TestPage_Cursor(t *testing.T) {
+	handler := jsonapi.Wrap(scrollPlans, jsonapi.WithPageLimits(2, 0))
+	next := regexp.MustCompile(`<([^>]+)>; rel="next"`)
+
+	var pages []string
+
+	// The client follows the next links, until there are no more
+	for target := "/plans"; target != ""; {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d does not match received %d", http.StatusOK, rec.Code)
+		}
+
+		pages = append(pages, rec.Body.String())
+		target = ""
+
+		if m := next.FindStringSubmatch(rec.Header().Get("Link")); m != nil {
+			target = m[1]
+		}
+	}
+
+	if len(pages) != 3 {
+		t.Fatalf("expected 3 pages, got %d: %v", len(pages), pages)
+	}
+
+	if !strings.HasPrefix(pages[1], `{"items":["gold","platinum"],"meta":{"limit":2,"next_cursor":"`) {
+		t.Errorf("unexpected second page %s", pages[1])
+	}
+
+	if !strings.HasPrefix(pages[2], `{"items":["diamond"],"meta":{"limit":2,"prev_cursor":"`) {
+		t.Errorf("unexpected last page %s", pages[2])
+	}
+
+	// Cursors cannot be forged
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/plans?cursor=YWdvbGQ.AAAAAAAAAAAAAAAAAAAAAA", http.NoBody))
+
+	expected := `{"status":400,"kind":"Invalid Request","code":"validation_failed","details":"Validation errors","errors":[{"field":"cursor","pointer":"/cursor","source":"query","code":"invalid_cursor","value":"YWdvbGQ.AAAAAAAAAAAAAAAAAAAAAA","msg":"The cursor is not valid"}]}` + "\n"
+	if rec.Body.String() != expected {
+		t.Errorf("response body does not match\nexpected: %s\nreceived: %s\n", expected, rec.Body.String())
+	}
+}
@@ -0,0 +1,121 @@
+package jsonapi
+
+import (
+	"errors"
+	"net/http"
+	"runtime/debug"
+	"strings"
+)
+
+// A PanicReporter is notified of the panics recovered by a handler, so they can be sent to
+// an error tracker.
+//
+// The stack is the one of the goroutine that panicked, as returned by debug.Stack.
+type PanicReporter interface {
+	ReportPanic(req *http.Request, v interface{}, stack []byte)
+}
+
+// PanicReporterFunc is a function that implements PanicReporter
+type PanicReporterFunc func(req *http.Request, v interface{}, stack []byte)
+
+func (fn PanicReporterFunc) ReportPanic(req *http.Request, v interface{}, stack []byte) {
+	fn(req, v, stack)
+}
+
+// WithPanicReporter sets the reporter of the panics recovered by the handler
+func WithPanicReporter(reporter PanicReporter) OptsFn {
+	return func(h *JsonHandler) {
+		h.PanicReporter = reporter
+	}
+}
+
+// WithDebug enables the debug mode of the handler.
+//
+// In debug mode, error responses include the chain of the error and, for panics, the
+// stack trace. It leaks internal details, so it must not be enabled in production.
+func WithDebug() OptsFn {
+	return func(h *JsonHandler) {
+		h.Debug = true
+	}
+}
+
+// recoverPanic recovers from a panic in any stage of the handler, reports it and sends
+// an error response. If the response has already been sent, the panic is only reported.
+//
+// It must be deferred directly, so recover stops the panic.
+func (h *JsonHandler) recoverPanic(w *responseWriter, req *http.Request) {
+	if h.SkipPanic {
+		return
+	}
+
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	var stack []byte
+
+	// Panics in the goroutine of a function with a timeout come with its stack
+	if gp, ok := r.(*goroutinePanic); ok {
+		r, stack = gp.v, gp.stack
+	} else {
+		stack = debug.Stack()
+	}
+
+	// The server aborts the response silently with this one, so we let it be
+	if r == http.ErrAbortHandler {
+		panic(r)
+	}
+
+	if m := h.metrics(); m != nil {
+		m.panicked(h.name())
+	}
+
+	if reporter := h.panicReporter(); reporter != nil {
+		reporter.ReportPanic(req, r, stack)
+	}
+
+	err := panicToError(r, stack)
+
+	if w.written() {
+		stateFrom(req).err = err
+		return
+	}
+
+	h.handleError(w, req, err)
+}
+
+func (h *JsonHandler) panicReporter() PanicReporter {
+	if h.PanicReporter != nil {
+		return h.PanicReporter
+	}
+
+	return h.api.PanicReporter
+}
+
+// debugInfo is added to error responses in debug mode
+type debugInfo struct {
+	Chain   []string      `json:"chain,omitempty"`
+	Stack   []string      `json:"stack,omitempty"`
+	Timings []timingEntry `json:"timings,omitempty"`
+}
+
+// newDebugInfo returns the debug info of an error, or nil if the request is not served in
+// debug mode.
+func newDebugInfo(req *http.Request, err error) *debugInfo {
+	h := handlerFrom(req)
+	if !apiFrom(req).Debug && (h == nil || !h.Debug) {
+		return nil
+	}
+
+	info := &debugInfo{Chain: errorChain(err), Timings: debugTimings(req)}
+
+	var e *apiError
+	if errors.As(err, &e) && e.stack != nil {
+		for _, line := range strings.Split(strings.TrimSpace(string(e.stack)), "\n") {
+			info.Stack = append(info.Stack, strings.TrimSpace(line))
+		}
+	}
+
+	return info
+}
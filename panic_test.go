@@ -0,0 +1,180 @@
+package jsonapi_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mnavarrocarter/jsonapi"
+)
+
+type panicResolver struct{}
+
+func (panicResolver) Resolve(_ *http.Request, _ reflect.Type, _ int) (reflect.Value, error) {
+	panic("resolver exploded")
+}
+
+func TestPanicRecovery(t *testing.T) {
+	panicSender := testAPI()
+	panicSender.SendResponse = func(w http.ResponseWriter, req *http.Request, v interface{}) {
+		if _, ok := v.(error); !ok {
+			panic("sender exploded")
+		}
+
+		jsonapi.SendResponse(w, req, v)
+	}
+
+	tt := []struct {
+		name             string
+		handler          http.Handler
+		expectedResponse []byte
+		expectedStatus   int
+	}{
+		{
+			name: "validator",
+			handler: testAPI().Wrap(func() {}, jsonapi.WithValidator(jsonapi.ValidatorFunc(func(req *http.Request) ([]*jsonapi.ErrorItem, error) {
+				panic("validator exploded")
+			}))),
+			expectedResponse: []byte(`{"status":500,"kind":"Internal Error","code":"unexpected_error","details":"An unexpected error has occurred","reference":"f00dcafe"}` + "\n"),
+			expectedStatus:   http.StatusInternalServerError,
+		},
+		{
+			name: "resolver",
+			handler: testAPI().Wrap(func(s string) {}, func(h *jsonapi.JsonHandler) {
+				h.ArgumentResolver = panicResolver{}
+			}),
+			expectedResponse: []byte(`{"status":500,"kind":"Internal Error","code":"unexpected_error","details":"An unexpected error has occurred","reference":"f00dcafe"}` + "\n"),
+			expectedStatus:   http.StatusInternalServerError,
+		},
+		{
+			name: "response sender",
+			handler: panicSender.Wrap(func() map[string]string {
+				return map[string]string{"msg": "success"}
+			}),
+			expectedResponse: []byte(`{"status":500,"kind":"Internal Error","code":"unexpected_error","details":"An unexpected error has occurred","reference":"f00dcafe"}` + "\n"),
+			expectedStatus:   http.StatusInternalServerError,
+		},
+		{
+			name: "after writing the response",
+			handler: (&jsonapi.API{
+				SendResponse: func(w http.ResponseWriter, req *http.Request, v interface{}) {
+					w.WriteHeader(http.StatusAccepted)
+					panic("too late")
+				},
+			}).Wrap(func() {}),
+			expectedStatus: http.StatusAccepted,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+
+			test.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", http.NoBody))
+
+			if test.expectedStatus != rec.Code {
+				t.Errorf("expected status %d does not match received %d", test.expectedStatus, rec.Code)
+			}
+
+			if !bytes.Equal(test.expectedResponse, rec.Body.Bytes()) {
+				t.Errorf(
+					"response body does not match\nexpected: %s\nreceived: %s\n",
+					string(test.expectedResponse),
+					rec.Body.String(),
+				)
+			}
+		})
+	}
+}
+
+func TestWithPanicReporter(t *testing.T) {
+	var reported interface{}
+	var stack []byte
+
+	reporter := jsonapi.PanicReporterFunc(func(req *http.Request, v interface{}, s []byte) {
+		reported, stack = v, s
+	})
+
+	handler := jsonapi.Wrap(func() {
+		panic("something really bad has happened")
+	}, jsonapi.WithPanicReporter(reporter))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if reported != "something really bad has happened" {
+		t.Errorf("expected the panic to be reported, got %v", reported)
+	}
+
+	if !bytes.Contains(stack, []byte("panic_test.go")) {
+		t.Errorf("expected the stack to include the panicking function, got:\n%s", stack)
+	}
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d does not match received %d", http.StatusInternalServerError, rec.Code)
+	}
+}
+
+func TestWithDebug(t *testing.T) {
+	tt := []struct {
+		name          string
+		handler       http.Handler
+		expectedChain []string
+		expectStack   bool
+	}{
+		{
+			name: "panic",
+			handler: jsonapi.Wrap(func() {
+				panic("something really bad has happened")
+			}, jsonapi.WithDebug()),
+			expectedChain: []string{"An unexpected error has occurred", "something really bad has happened"},
+			expectStack:   true,
+		},
+		{
+			name: "error",
+			handler: jsonapi.Wrap(func() error {
+				return customErr(http.StatusBadRequest, errConflict, "customer error")
+			}, jsonapi.WithDebug()),
+			expectedChain: []string{"customer error", "plan already exists"},
+		},
+		{
+			name: "api",
+			handler: (&jsonapi.API{Debug: true}).Wrap(func() {
+				panic("something really bad has happened")
+			}),
+			expectedChain: []string{"An unexpected error has occurred", "something really bad has happened"},
+			expectStack:   true,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			test.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+			var body struct {
+				Debug struct {
+					Chain []string `json:"chain"`
+					Stack []string `json:"stack"`
+				} `json:"debug"`
+			}
+
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(test.expectedChain, body.Debug.Chain) {
+				t.Errorf("expected chain %q does not match received %q", test.expectedChain, body.Debug.Chain)
+			}
+
+			stack := strings.Join(body.Debug.Stack, "\n")
+			if test.expectStack != strings.Contains(stack, "panic_test.go") {
+				t.Errorf("unexpected stack:\n%s", stack)
+			}
+		})
+	}
+}
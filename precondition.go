@@ -0,0 +1,93 @@
+package jsonapi
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// IfMatch is the If-Match precondition of a request, the list of the ETags the client
+// expects the resource to have. It is empty when the client sent none.
+//
+// Functions can take it as an argument, to protect updates from overwriting the changes
+// made by others since the client read the resource:
+//
+//	func UpdatePlan(ifMatch jsonapi.IfMatch, cmd *UpdatePlanCmd) (*Plan, error) {
+//		plan := plans.Find(cmd.ID)
+//		if err := ifMatch.Check(plan.ETag()); err != nil {
+//			return nil, err
+//		}
+//		// ...
+//	}
+type IfMatch string
+
+var ifMatchType = reflect.TypeOf(IfMatch(""))
+
+// ErrPreconditionFailed is returned by functions when the If-Match precondition of the
+// request does not match the current version of the resource, and ends in a 412 response.
+var ErrPreconditionFailed error = &apiError{
+	code:    http.StatusPreconditionFailed,
+	msg:     "The resource has been modified since it was read",
+	errCode: "precondition_failed",
+	kind:    KindPreconditionFailed,
+}
+
+// WithIfMatchRequired makes the handler reject the PUT, PATCH and DELETE requests that come
+// without an If-Match header with a 428 error, so clients cannot skip the precondition.
+func WithIfMatchRequired() OptsFn {
+	return func(h *JsonHandler) {
+		h.IfMatchRequired = true
+	}
+}
+
+// Present tells whether the client sent an If-Match header
+func (m IfMatch) Present() bool {
+	return m != ""
+}
+
+// Matches tells whether the precondition holds for a resource with the given ETag, or
+// there is no precondition.
+//
+// ETags are compared strongly, so weak ETags never match, except with "*". The ETag can
+// be given with or without its quotes, like ETagger values.
+func (m IfMatch) Matches(etag string) bool {
+	if !m.Present() {
+		return true
+	}
+
+	return matchETag(string(m), quoteETag(etag), false)
+}
+
+// Check returns ErrPreconditionFailed if the precondition does not hold for a resource
+// with the given ETag.
+func (m IfMatch) Check(etag string) error {
+	if !m.Matches(etag) {
+		return ErrPreconditionFailed
+	}
+
+	return nil
+}
+
+// ifMatch returns the If-Match precondition of a request
+func ifMatch(req *http.Request) IfMatch {
+	return IfMatch(strings.Join(req.Header.Values("If-Match"), ", "))
+}
+
+// requirePrecondition fails with a 428 error if the If-Match header is required and missing
+func (h *JsonHandler) requirePrecondition(req *http.Request) error {
+	if !h.IfMatchRequired || ifMatch(req).Present() {
+		return nil
+	}
+
+	switch req.Method {
+	case http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return &apiError{
+			code:    http.StatusPreconditionRequired,
+			msg:     "The request must include an If-Match header",
+			errCode: "precondition_required",
+			kind:    KindInvalidRequest,
+		}
+	default:
+		return nil
+	}
+}
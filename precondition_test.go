@@ -0,0 +1,134 @@
+package jsonapi_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mnavarrocarter/jsonapi"
+)
+
+func TestIfMatch(t *testing.T) {
+	version := "v3"
+
+	update := func(ifMatch jsonapi.IfMatch, cmd *testCmd) (*testResp, error) {
+		if err := ifMatch.Check(version); err != nil {
+			return nil, err
+		}
+
+		return &testResp{Msg: "updated " + cmd.Name}, nil
+	}
+
+	optional := jsonapi.Wrap(update)
+	required := jsonapi.Wrap(update, jsonapi.WithIfMatchRequired())
+
+	wrapped := jsonapi.Wrap(func(ifMatch jsonapi.IfMatch, cmd *testCmd) (*testResp, error) {
+		if err := ifMatch.Check(version); err != nil {
+			return nil, fmt.Errorf("update plan: %w", err)
+		}
+
+		return &testResp{Msg: "updated " + cmd.Name}, nil
+	})
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		method  string
+		ifMatch []string
+		status  int
+		body    string
+	}{
+		{
+			name:    "matching version",
+			handler: required,
+			method:  http.MethodPut,
+			ifMatch: []string{`"v3"`},
+			status:  http.StatusOK,
+			body:    `{"msg":"updated gold"}` + "\n",
+		},
+		{
+			name:    "one of the versions matches",
+			handler: required,
+			method:  http.MethodPatch,
+			ifMatch: []string{`"v1", "v2"`, `"v3"`},
+			status:  http.StatusOK,
+			body:    `{"msg":"updated gold"}` + "\n",
+		},
+		{
+			name:    "any version",
+			handler: required,
+			method:  http.MethodPut,
+			ifMatch: []string{"*"},
+			status:  http.StatusOK,
+			body:    `{"msg":"updated gold"}` + "\n",
+		},
+		{
+			name:    "stale version",
+			handler: required,
+			method:  http.MethodPut,
+			ifMatch: []string{`"v2"`},
+			status:  http.StatusPreconditionFailed,
+			body:    `{"status":412,"kind":"Precondition Failed","code":"precondition_failed","details":"The resource has been modified since it was read"}` + "\n",
+		},
+		{
+			name:    "wrapped precondition error",
+			handler: wrapped,
+			method:  http.MethodPut,
+			ifMatch: []string{`"v2"`},
+			status:  http.StatusPreconditionFailed,
+			body:    `{"status":412,"kind":"Precondition Failed","code":"precondition_failed","details":"The resource has been modified since it was read"}` + "\n",
+		},
+		{
+			name:    "weak etags never match",
+			handler: required,
+			method:  http.MethodPut,
+			ifMatch: []string{`W/"v3"`},
+			status:  http.StatusPreconditionFailed,
+			body:    `{"status":412,"kind":"Precondition Failed","code":"precondition_failed","details":"The resource has been modified since it was read"}` + "\n",
+		},
+		{
+			name:    "missing precondition",
+			handler: required,
+			method:  http.MethodPut,
+			status:  http.StatusPreconditionRequired,
+			body:    `{"status":428,"kind":"Invalid Request","code":"precondition_required","details":"The request must include an If-Match header"}` + "\n",
+		},
+		{
+			name:    "missing precondition on creation",
+			handler: required,
+			method:  http.MethodPost,
+			status:  http.StatusOK,
+			body:    `{"msg":"updated gold"}` + "\n",
+		},
+		{
+			name:    "optional precondition",
+			handler: optional,
+			method:  http.MethodPut,
+			status:  http.StatusOK,
+			body:    `{"msg":"updated gold"}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(test.method, "/plans/gold", strings.NewReader(`{"name":"gold"}`))
+
+			for _, v := range test.ifMatch {
+				req.Header.Add("If-Match", v)
+			}
+
+			test.handler.ServeHTTP(rec, req)
+
+			if test.status != rec.Code {
+				t.Errorf("expected status %d does not match received %d", test.status, rec.Code)
+			}
+
+			if test.body != rec.Body.String() {
+				t.Errorf("response body does not match\nexpected: %s\nreceived: %s\n", test.body, rec.Body.String())
+			}
+		})
+	}
+}
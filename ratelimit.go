@@ -0,0 +1,205 @@
+package jsonapi
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A RateLimiter decides whether a client, identified by a key, can make a request
+type RateLimiter interface {
+	Allow(key string) RateLimit
+}
+
+// RateLimit is the decision of a RateLimiter, and the quota left to the client
+type RateLimit struct {
+	Allowed    bool
+	Limit      int           // The requests allowed in a burst
+	Remaining  int           // The requests the client can still make right away
+	Reset      time.Duration // The time until the quota is whole again
+	RetryAfter time.Duration // The time until the next request is allowed, when it is not
+}
+
+// A KeyFunc returns the key of a request, like the client that sent it for rate limiting,
+// or the response it gets for caching
+type KeyFunc func(req *http.Request) string
+
+// ByIP identifies clients by their IP address.
+//
+// It uses the address of the connection. Behind a proxy, use a KeyFunc that reads the
+// address from the header the proxy sets.
+func ByIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
+
+// ByHeader identifies clients by a header, like the one with their API key
+func ByHeader(name string) KeyFunc {
+	return func(req *http.Request) string {
+		return req.Header.Get(name)
+	}
+}
+
+// ByContextValue identifies clients by a value of the context of the request, like the
+// authenticated principal set by an authentication middleware.
+func ByContextValue(key interface{}) KeyFunc {
+	return func(req *http.Request) string {
+		v := req.Context().Value(key)
+		if v == nil {
+			return ""
+		}
+
+		return fmt.Sprint(v)
+	}
+}
+
+// WithRateLimit limits the requests clients, identified by key, can make to the handler.
+//
+// Limited requests get a 429 error with the Retry-After header. All responses include the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers.
+//
+// Requests whose key is empty share the same quota.
+func WithRateLimit(limiter RateLimiter, key KeyFunc) OptsFn {
+	return func(h *JsonHandler) {
+		h.RateLimiter = limiter
+		h.RateLimitKey = key
+	}
+}
+
+// rateLimit applies the rate limit of the handler to a request. It returns an error if
+// the request is not allowed.
+func (h *JsonHandler) rateLimit(w http.ResponseWriter, req *http.Request) error {
+	if h.RateLimiter == nil {
+		return nil
+	}
+
+	key := ByIP
+	if h.RateLimitKey != nil {
+		key = h.RateLimitKey
+	}
+
+	limit := h.RateLimiter.Allow(key(req))
+
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(limit.Remaining))
+	w.Header().Set("RateLimit-Reset", seconds(limit.Reset))
+
+	if limit.Allowed {
+		return nil
+	}
+
+	retryAfter := seconds(limit.RetryAfter)
+	w.Header().Set("Retry-After", retryAfter)
+
+	return &apiError{
+		code:    http.StatusTooManyRequests,
+		msg:     fmt.Sprintf("Too many requests, try again in %s seconds", retryAfter),
+		errCode: "rate_limited",
+		kind:    KindRateLimited,
+		params:  map[string]interface{}{"retry_after": retryAfter},
+	}
+}
+
+// seconds renders a duration in whole seconds, rounded up
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// TokenBucket is an in-memory RateLimiter that gives each client a bucket of tokens.
+//
+// Each request takes a token, and buckets are refilled at a constant rate. Clients can
+// make a burst of as many requests as tokens fit in a bucket.
+type TokenBucket struct {
+	mu        sync.Mutex
+	capacity  float64
+	rate      float64 // Tokens per second
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket makes a TokenBucket that allows limit requests every period, like 100
+// requests per minute, in bursts of up to limit requests.
+//
+// It panics if limit or period are not positive.
+func NewTokenBucket(limit int, period time.Duration) *TokenBucket {
+	if limit <= 0 {
+		panic(fmt.Sprintf("token bucket must have a positive limit, got %d", limit))
+	}
+
+	if period <= 0 {
+		panic(fmt.Sprintf("token bucket must have a positive period, got %s", period))
+	}
+
+	return &TokenBucket{
+		capacity:  float64(limit),
+		rate:      float64(limit) / period.Seconds(),
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (tb *TokenBucket) Allow(key string) RateLimit {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	now := time.Now()
+	tb.sweep(now)
+
+	b, ok := tb.buckets[key]
+	if !ok {
+		b = &bucket{tokens: tb.capacity, last: now}
+		tb.buckets[key] = b
+	}
+
+	b.tokens = math.Min(tb.capacity, b.tokens+now.Sub(b.last).Seconds()*tb.rate)
+	b.last = now
+
+	limit := RateLimit{Limit: int(tb.capacity)}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		limit.Allowed = true
+	} else {
+		limit.RetryAfter = tb.duration(1 - b.tokens)
+	}
+
+	limit.Remaining = int(b.tokens)
+	limit.Reset = tb.duration(tb.capacity - b.tokens)
+
+	return limit
+}
+
+// duration returns the time it takes to refill some tokens
+func (tb *TokenBucket) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / tb.rate * float64(time.Second))
+}
+
+// sweep forgets the buckets that are full, as they are like new ones. It runs at most once
+// for the time it takes to fill a bucket. It must be called with the lock held.
+func (tb *TokenBucket) sweep(now time.Time) {
+	fill := tb.duration(tb.capacity)
+	if now.Sub(tb.lastSweep) < fill {
+		return
+	}
+
+	for key, b := range tb.buckets {
+		if now.Sub(b.last) >= fill {
+			delete(tb.buckets, key)
+		}
+	}
+
+	tb.lastSweep = now
+}
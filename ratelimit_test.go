@@ -0,0 +1,182 @@
+package jsonapi_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mnavarrocarter/jsonapi"
+)
+
+type principalKey struct{}
+
+func TestWithRateLimit(t *testing.T) {
+	type response struct {
+		status    int
+		remaining string
+		reset     string
+		body      []byte
+	}
+
+	limited := []byte(`{"status":429,"kind":"Rate Limited","code":"rate_limited","details":"Too many requests, try again in 1 seconds"}` + "\n")
+
+	tt := []struct {
+		name     string
+		key      jsonapi.KeyFunc
+		requests []func(req *http.Request) *http.Request
+		expected []response
+	}{
+		{
+			name: "by ip",
+			requests: []func(req *http.Request) *http.Request{
+				func(req *http.Request) *http.Request { return req },
+				func(req *http.Request) *http.Request { return req },
+				func(req *http.Request) *http.Request { return req },
+				func(req *http.Request) *http.Request {
+					req.RemoteAddr = "192.0.2.2:1234"
+					return req
+				},
+			},
+			expected: []response{
+				{status: http.StatusNoContent, remaining: "1", reset: "1"},
+				{status: http.StatusNoContent, remaining: "0", reset: "2"},
+				{status: http.StatusTooManyRequests, remaining: "0", reset: "2", body: limited},
+				{status: http.StatusNoContent, remaining: "1", reset: "1"},
+			},
+		},
+		{
+			name: "by header",
+			key:  jsonapi.ByHeader("X-API-Key"),
+			requests: []func(req *http.Request) *http.Request{
+				func(req *http.Request) *http.Request {
+					req.Header.Set("X-API-Key", "acme")
+					return req
+				},
+				func(req *http.Request) *http.Request {
+					req.Header.Set("X-API-Key", "acme")
+					return req
+				},
+				func(req *http.Request) *http.Request {
+					req.Header.Set("X-API-Key", "acme")
+					return req
+				},
+				func(req *http.Request) *http.Request {
+					req.Header.Set("X-API-Key", "globex")
+					return req
+				},
+			},
+			expected: []response{
+				{status: http.StatusNoContent, remaining: "1", reset: "1"},
+				{status: http.StatusNoContent, remaining: "0", reset: "2"},
+				{status: http.StatusTooManyRequests, remaining: "0", reset: "2", body: limited},
+				{status: http.StatusNoContent, remaining: "1", reset: "1"},
+			},
+		},
+		{
+			name: "by principal",
+			key:  jsonapi.ByContextValue(principalKey{}),
+			requests: []func(req *http.Request) *http.Request{
+				func(req *http.Request) *http.Request {
+					return req.WithContext(context.WithValue(req.Context(), principalKey{}, "alice"))
+				},
+				func(req *http.Request) *http.Request {
+					return req.WithContext(context.WithValue(req.Context(), principalKey{}, "bob"))
+				},
+				func(req *http.Request) *http.Request {
+					return req.WithContext(context.WithValue(req.Context(), principalKey{}, "alice"))
+				},
+				func(req *http.Request) *http.Request {
+					return req.WithContext(context.WithValue(req.Context(), principalKey{}, "alice"))
+				},
+			},
+			expected: []response{
+				{status: http.StatusNoContent, remaining: "1", reset: "1"},
+				{status: http.StatusNoContent, remaining: "1", reset: "1"},
+				{status: http.StatusNoContent, remaining: "0", reset: "2"},
+				{status: http.StatusTooManyRequests, remaining: "0", reset: "2", body: limited},
+			},
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			handler := jsonapi.Wrap(func() {}, jsonapi.WithRateLimit(jsonapi.NewTokenBucket(2, 2*time.Second), test.key))
+
+			for i, mod := range test.requests {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, mod(httptest.NewRequest(http.MethodPost, "/reports", http.NoBody)))
+
+				expected := test.expected[i]
+
+				if expected.status != rec.Code {
+					t.Errorf("request #%d: expected status %d does not match received %d", i, expected.status, rec.Code)
+				}
+
+				if limit := rec.Header().Get("RateLimit-Limit"); limit != "2" {
+					t.Errorf("request #%d: unexpected RateLimit-Limit %q", i, limit)
+				}
+
+				if remaining := rec.Header().Get("RateLimit-Remaining"); expected.remaining != remaining {
+					t.Errorf("request #%d: expected RateLimit-Remaining %q does not match received %q", i, expected.remaining, remaining)
+				}
+
+				if reset := rec.Header().Get("RateLimit-Reset"); expected.reset != reset {
+					t.Errorf("request #%d: expected RateLimit-Reset %q does not match received %q", i, expected.reset, reset)
+				}
+
+				if expected.status == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "1" {
+					t.Errorf("request #%d: unexpected Retry-After %q", i, rec.Header().Get("Retry-After"))
+				}
+
+				if !bytes.Equal(expected.body, rec.Body.Bytes()) {
+					t.Errorf("request #%d: response body does not match\nexpected: %s\nreceived: %s\n", i, expected.body, rec.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestTokenBucket_Refill(t *testing.T) {
+	limiter := jsonapi.NewTokenBucket(1, 20*time.Millisecond)
+
+	if !limiter.Allow("acme").Allowed {
+		t.Fatal("first request should be allowed")
+	}
+
+	if limiter.Allow("acme").Allowed {
+		t.Fatal("second request should not be allowed")
+	}
+
+	time.Sleep(25 * time.Millisecond)
+
+	if !limiter.Allow("acme").Allowed {
+		t.Fatal("request should be allowed once the bucket is refilled")
+	}
+}
+
+func TestNewTokenBucket_Invalid(t *testing.T) {
+	tt := []struct {
+		name   string
+		limit  int
+		period time.Duration
+	}{
+		{name: "no limit", limit: 0, period: time.Minute},
+		{name: "negative limit", limit: -1, period: time.Minute},
+		{name: "no period", limit: 100, period: 0},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("invalid token bucket should have panicked")
+				}
+			}()
+
+			jsonapi.NewTokenBucket(test.limit, test.period)
+		})
+	}
+}
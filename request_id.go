@@ -0,0 +1,105 @@
+package jsonapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"reflect"
+)
+
+// DefaultRequestIDHeader is the header of the request ID when none is given to WithRequestID
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the maximum length of the request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// A RequestID identifies a request across services and logs.
+//
+// Functions can take it as an argument.
+type RequestID string
+
+var requestIDType = reflect.TypeOf(RequestID(""))
+
+type requestIDKey struct{}
+
+// NewRequestID generates the IDs of the requests that do not come with one
+var NewRequestID = func() RequestID {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return RequestID(hex.EncodeToString(b))
+}
+
+// WithRequestID makes the handler identify each request.
+//
+// The ID is taken from the header of the request, or generated with NewRequestID when it
+// is missing or invalid. It is sent back in the same header, and included in the error
+// responses and the log lines of the request. When the header is empty,
+// DefaultRequestIDHeader is used.
+func WithRequestID(header string) OptsFn {
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+
+	return func(h *JsonHandler) {
+		h.RequestIDHeader = header
+	}
+}
+
+// RequestIDFrom returns the ID of the request the context belongs to, if any
+func RequestIDFrom(ctx context.Context) RequestID {
+	id, _ := ctx.Value(requestIDKey{}).(RequestID)
+
+	return id
+}
+
+// requestID returns the ID of the request, if any
+func requestID(req *http.Request) RequestID {
+	if req == nil {
+		return ""
+	}
+
+	return RequestIDFrom(req.Context())
+}
+
+func (h *JsonHandler) requestIDHeader() string {
+	if h.RequestIDHeader != "" {
+		return h.RequestIDHeader
+	}
+
+	return h.api.RequestIDHeader
+}
+
+// withRequestID identifies a request by the given header, and echoes its ID in the response.
+//
+// Requests are left untouched if the header is empty.
+func withRequestID(w http.ResponseWriter, req *http.Request, header string) *http.Request {
+	if header == "" {
+		return req
+	}
+
+	id := RequestID(req.Header.Get(header))
+	if !validRequestID(id) {
+		id = NewRequestID()
+	}
+
+	w.Header().Set(header, string(id))
+
+	return req.WithContext(context.WithValue(req.Context(), requestIDKey{}, id))
+}
+
+// validRequestID tells whether an ID sent by a client is safe to log and send back
+func validRequestID(id RequestID) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,137 @@
+package jsonapi_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mnavarrocarter/jsonapi"
+)
+
+func TestWithRequestID(t *testing.T) {
+	newRequestID := jsonapi.NewRequestID
+	jsonapi.NewRequestID = func() jsonapi.RequestID {
+		return "generated"
+	}
+	defer func() {
+		jsonapi.NewRequestID = newRequestID
+	}()
+
+	failing := func() error {
+		return customErr(http.StatusConflict, errConflict, "plan already exists")
+	}
+
+	echo := func(id jsonapi.RequestID) map[string]string {
+		return map[string]string{"id": string(id)}
+	}
+
+	tt := []struct {
+		name             string
+		handler          http.Handler
+		header           string
+		id               string
+		expectedID       string
+		expectedResponse []byte
+	}{
+		{
+			name:             "incoming",
+			handler:          jsonapi.Wrap(failing, jsonapi.WithRequestID(""), jsonapi.WithErrorMapping(conflictMapping)),
+			header:           jsonapi.DefaultRequestIDHeader,
+			id:               "abc-123",
+			expectedID:       "abc-123",
+			expectedResponse: []byte(`{"status":409,"code":"plan_conflict","details":"plan already exists","request_id":"abc-123"}` + "\n"),
+		},
+		{
+			name:             "generated",
+			handler:          jsonapi.Wrap(failing, jsonapi.WithRequestID(""), jsonapi.WithErrorMapping(conflictMapping)),
+			header:           jsonapi.DefaultRequestIDHeader,
+			expectedID:       "generated",
+			expectedResponse: []byte(`{"status":409,"code":"plan_conflict","details":"plan already exists","request_id":"generated"}` + "\n"),
+		},
+		{
+			name:             "invalid",
+			handler:          jsonapi.Wrap(failing, jsonapi.WithRequestID(""), jsonapi.WithErrorMapping(conflictMapping)),
+			header:           jsonapi.DefaultRequestIDHeader,
+			id:               "abc\x00123",
+			expectedID:       "generated",
+			expectedResponse: []byte(`{"status":409,"code":"plan_conflict","details":"plan already exists","request_id":"generated"}` + "\n"),
+		},
+		{
+			name:             "too long",
+			handler:          jsonapi.Wrap(failing, jsonapi.WithRequestID(""), jsonapi.WithErrorMapping(conflictMapping)),
+			header:           jsonapi.DefaultRequestIDHeader,
+			id:               strings.Repeat("a", 129),
+			expectedID:       "generated",
+			expectedResponse: []byte(`{"status":409,"code":"plan_conflict","details":"plan already exists","request_id":"generated"}` + "\n"),
+		},
+		{
+			name:             "custom header",
+			handler:          jsonapi.Wrap(echo, jsonapi.WithRequestID("X-Correlation-ID")),
+			header:           "X-Correlation-ID",
+			id:               "abc-123",
+			expectedID:       "abc-123",
+			expectedResponse: []byte(`{"id":"abc-123"}` + "\n"),
+		},
+		{
+			name:             "problem details",
+			handler:          jsonapi.Wrap(failing, jsonapi.WithRequestID(""), jsonapi.WithErrorMapping(conflictMapping), jsonapi.WithErrorFormat(jsonapi.ProblemErrors)),
+			header:           jsonapi.DefaultRequestIDHeader,
+			id:               "abc-123",
+			expectedID:       "abc-123",
+			expectedResponse: []byte(`{"type":"about:blank","title":"Conflict","status":409,"detail":"plan already exists","instance":"/plans","code":"plan_conflict","request_id":"abc-123"}` + "\n"),
+		},
+		{
+			name:             "api",
+			handler:          (&jsonapi.API{RequestIDHeader: jsonapi.DefaultRequestIDHeader}).NotFoundHandler(),
+			header:           jsonapi.DefaultRequestIDHeader,
+			id:               "abc-123",
+			expectedID:       "abc-123",
+			expectedResponse: []byte(`{"status":404,"kind":"Not Found","code":"not_found","details":"No handler found for POST /plans","request_id":"abc-123"}` + "\n"),
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/plans", http.NoBody)
+
+			if test.id != "" {
+				req.Header.Set(test.header, test.id)
+			}
+
+			test.handler.ServeHTTP(rec, req)
+
+			if id := rec.Header().Get(test.header); id != test.expectedID {
+				t.Errorf("expected request id %q does not match received %q", test.expectedID, id)
+			}
+
+			if !bytes.Equal(test.expectedResponse, rec.Body.Bytes()) {
+				t.Errorf(
+					"response body does not match\nexpected: %s\nreceived: %s\n",
+					string(test.expectedResponse),
+					rec.Body.String(),
+				)
+			}
+		})
+	}
+}
+
+func TestWithRequestID_Logs(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	handler := jsonapi.Wrap(func() error {
+		return errors.New("connection refused")
+	}, jsonapi.WithRequestID(""), jsonapi.WithLogger(newTestLogger(buf)))
+
+	req := httptest.NewRequest(http.MethodDelete, "/plans/1234", http.NoBody)
+	req.Header.Set(jsonapi.DefaultRequestIDHeader, "abc-123")
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !strings.Contains(buf.String(), "request_id=abc-123") {
+		t.Errorf("expected the log to include the request id, got: %s", buf.String())
+	}
+}
@@ -2,45 +2,147 @@ package jsonapi
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
+// ErrorFormat is the format in which errors are rendered
+type ErrorFormat int
+
+const (
+	// JSONErrors renders errors as {"status": 400, "details": "...", "errors": [...]}
+	JSONErrors ErrorFormat = iota + 1
+	// ProblemErrors renders errors as RFC 9457 problem details, with the application/problem+json content type
+	ProblemErrors
+)
+
+// DefaultErrorFormat is the format used by handlers that do not set their own ErrorFormat,
+// and by the static handlers.
+var DefaultErrorFormat = JSONErrors
+
+// WithErrorFormat sets the format in which the handler renders errors
+func WithErrorFormat(format ErrorFormat) OptsFn {
+	return func(h *JsonHandler) {
+		h.ErrorFormat = format
+	}
+}
+
 type errorResponse struct {
 	StatusCode int          `json:"status"`
+	Kind       string       `json:"kind,omitempty"`
+	Code       string       `json:"code,omitempty"`
 	Details    string       `json:"details"`
 	Errors     []*ErrorItem `json:"errors,omitempty"`
+	Reference  string       `json:"reference,omitempty"`
+	RequestID  RequestID    `json:"request_id,omitempty"`
+	Debug      *debugInfo   `json:"debug,omitempty"`
+
+	extra       map[string]interface{} // Extra members, from a Detailer
+	problemType string                 // The type of the problem, when rendered as problem details
+}
+
+// MarshalJSON renders the extra members at the same level than the standard ones
+func (e *errorResponse) MarshalJSON() ([]byte, error) {
+	type response errorResponse
+
+	b, err := json.Marshal((*response)(e))
+	if err != nil {
+		return nil, err
+	}
+
+	return appendMembers(b, e.extra, "status", "kind", "code", "details", "errors", "reference", "request_id", "debug")
+}
+
+// problemResponse is an RFC 9457 problem details object
+type problemResponse struct {
+	Type       string                 `json:"type"`
+	Title      string                 `json:"title"`
+	Status     int                    `json:"status"`
+	Detail     string                 `json:"detail,omitempty"`
+	Instance   string                 `json:"instance,omitempty"`
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON renders the extension members at the same level than the standard ones
+func (p *problemResponse) MarshalJSON() ([]byte, error) {
+	type problem problemResponse
+
+	b, err := json.Marshal((*problem)(p))
+	if err != nil {
+		return nil, err
+	}
+
+	return appendMembers(b, p.Extensions, "type", "title", "status", "detail", "instance")
+}
+
+// appendMembers adds members to an encoded json object.
+//
+// Members named like one of the reserved ones are skipped, so they cannot override them.
+func appendMembers(obj []byte, members map[string]interface{}, reserved ...string) ([]byte, error) {
+	if len(members) == 0 {
+		return obj, nil
+	}
+
+	m := make(map[string]interface{}, len(members))
+
+outer:
+	for k, v := range members {
+		for _, r := range reserved {
+			if k == r {
+				continue outer
+			}
+		}
+
+		m[k] = v
+	}
+
+	if len(m) == 0 {
+		return obj, nil
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	// We join both objects, removing the closing brace of the first and the opening of the second
+	obj = append(obj[:len(obj)-1], ',')
+
+	return append(obj, b[1:]...), nil
 }
 
 var SendResponse = sendResponse
 
 type ResponseSenderFunc = func(w http.ResponseWriter, req *http.Request, v interface{})
 
-func sendResponse(w http.ResponseWriter, _ *http.Request, v interface{}) {
+func sendResponse(w http.ResponseWriter, req *http.Request, v interface{}) {
 	if v == nil {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
 	status := http.StatusOK
+	contentType := "application/json"
+
+	if ve, ok := v.(*ValidationError); ok {
+		v = ve.Items
+	}
 
 	switch t := v.(type) {
 	case error:
-		status = http.StatusInternalServerError
-		if c, ok := v.(Coder); ok {
-			status = c.Code()
-		}
-
-		v = &errorResponse{
-			StatusCode: status,
-			Details:    t.Error(),
-		}
+		resp := newErrorResponse(req, t)
+		status = resp.StatusCode
+		v, contentType = renderError(req, resp)
 	case []*ErrorItem:
 		status = http.StatusBadRequest
-		v = &errorResponse{
+		v, contentType = renderError(req, &errorResponse{
 			StatusCode: status,
-			Details:    "Validation errors",
-			Errors:     t,
-		}
+			Kind:       KindInvalidRequest,
+			Code:       "validation_failed",
+			Details:    translate(req, "validation_failed", nil, "Validation errors"),
+			Errors:     translateItems(req, t),
+			RequestID:  requestID(req),
+		})
 	default:
 		// Override status code if we can
 		if c, ok := v.(Coder); ok {
@@ -48,7 +150,158 @@ func sendResponse(w http.ResponseWriter, _ *http.Request, v interface{}) {
 		}
 	}
 
-	w.Header().Add("Content-Type", "application/json")
+	w.Header().Add("Content-Type", contentType)
 	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(v)
 }
+
+// newErrorResponse builds the response for an error, using the optional interfaces it,
+// or any error in its chain, implements.
+//
+// Error mappings take precedence over the status code of a Coder.
+//
+// The messages of errors ending in a 5xx response are not sent to the client, as they
+// may leak internal details. See Public.
+func newErrorResponse(req *http.Request, err error) *errorResponse {
+	resp := &errorResponse{
+		StatusCode: http.StatusInternalServerError,
+		Details:    err.Error(),
+		RequestID:  requestID(req),
+	}
+
+	var c Coder
+	if errors.As(err, &c) {
+		resp.StatusCode = c.Code()
+	}
+
+	var e *apiError
+	if errors.As(err, &e) {
+		resp.Details = translate(req, e.errCode, e.params, resp.Details)
+	}
+
+	var ec ErrorCoder
+	if errors.As(err, &ec) {
+		resp.Code = ec.ErrorCode()
+	}
+
+	var k Kinder
+	if errors.As(err, &k) {
+		resp.Kind = k.Kind()
+	}
+
+	var d Detailer
+	if errors.As(err, &d) {
+		resp.extra = d.Details()
+	}
+
+	var pt ProblemTyper
+	if errors.As(err, &pt) {
+		resp.problemType = pt.ProblemType()
+	}
+
+	resp.Debug = newDebugInfo(req, err)
+
+	public := false
+
+	var p PublicError
+	if errors.As(err, &p) {
+		public = p.Public()
+	}
+
+	if m, ok := lookupErrorMapping(req, err); ok {
+		resp.StatusCode = m.Status
+
+		if m.Code != "" {
+			resp.Code = m.Code
+		}
+
+		if m.Message != "" {
+			resp.Details = translate(req, m.Code, nil, m.Message)
+			public = true
+		}
+	}
+
+	if resp.StatusCode < http.StatusInternalServerError {
+		return resp
+	}
+
+	// Internal errors are logged with a reference that is sent to the client instead of
+	// the message, unless the message is public.
+	a := apiFrom(req)
+	resp.Reference = a.newErrorReference()
+	stateFrom(req).reference = resp.Reference
+	a.logInternalError(req, resp.Reference, err)
+
+	if !public {
+		resp.Details = translate(req, "unexpected_error", nil, "An unexpected error has occurred")
+		resp.Code = "unexpected_error"
+		resp.Kind = KindInternal
+		resp.extra = nil
+	}
+
+	return resp
+}
+
+// renderError renders an errorResponse in the error format of the request's handler
+//
+// It returns the value to encode and its content type.
+func renderError(req *http.Request, e *errorResponse) (interface{}, string) {
+	if errorFormat(req) != ProblemErrors {
+		return e, "application/json"
+	}
+
+	p := &problemResponse{
+		Type:   e.problemType,
+		Title:  http.StatusText(e.StatusCode),
+		Status: e.StatusCode,
+		Detail: e.Details,
+	}
+
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+
+	if req != nil {
+		p.Instance = req.URL.Path
+	}
+
+	p.Extensions = make(map[string]interface{}, len(e.extra)+3)
+	for k, v := range e.extra {
+		p.Extensions[k] = v
+	}
+
+	if e.Code != "" {
+		p.Extensions["code"] = e.Code
+	}
+
+	if e.Kind != "" {
+		p.Extensions["kind"] = e.Kind
+	}
+
+	if e.Reference != "" {
+		p.Extensions["reference"] = e.Reference
+	}
+
+	if len(e.Errors) != 0 {
+		p.Extensions["errors"] = e.Errors
+	}
+
+	if e.RequestID != "" {
+		p.Extensions["request_id"] = e.RequestID
+	}
+
+	if e.Debug != nil {
+		p.Extensions["debug"] = e.Debug
+	}
+
+	return p, "application/problem+json"
+}
+
+// errorFormat returns the error format for the request
+func errorFormat(req *http.Request) ErrorFormat {
+	if h := handlerFrom(req); h != nil && h.ErrorFormat != 0 {
+		return h.ErrorFormat
+	}
+
+	return apiFrom(req).errorFormat()
+}
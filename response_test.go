@@ -0,0 +1,261 @@
+package jsonapi_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mnavarrocarter/jsonapi"
+)
+
+type outOfStockErr struct {
+	sku string
+}
+
+func (e *outOfStockErr) Error() string {
+	return fmt.Sprintf("product %s is out of stock", e.sku)
+}
+
+func (e *outOfStockErr) Code() int {
+	return http.StatusConflict
+}
+
+func (e *outOfStockErr) ProblemType() string {
+	return "https://example.com/problems/out-of-stock"
+}
+
+func (e *outOfStockErr) ErrorCode() string {
+	return "out_of_stock"
+}
+
+func (e *outOfStockErr) Kind() string {
+	return "Conflict"
+}
+
+func (e *outOfStockErr) Details() map[string]interface{} {
+	return map[string]interface{}{"sku": e.sku, "status": 200}
+}
+
+func TestErrorResponse(t *testing.T) {
+	tt := []struct {
+		name             string
+		handler          http.Handler
+		expectedResponse []byte
+		expectedStatus   int
+	}{
+		{
+			name: "domain error with code, kind and details",
+			handler: jsonapi.Wrap(func() error {
+				return &outOfStockErr{sku: "AB-1234"}
+			}),
+			expectedResponse: []byte(`{"status":409,"kind":"Conflict","code":"out_of_stock","details":"product AB-1234 is out of stock","sku":"AB-1234"}` + "\n"),
+			expectedStatus:   http.StatusConflict,
+		},
+		{
+			name: "wrapped domain error",
+			handler: jsonapi.Wrap(func() error {
+				return customErr(http.StatusUnprocessableEntity, &outOfStockErr{sku: "AB-1234"}, "could not place the order")
+			}),
+			expectedResponse: []byte(`{"status":422,"kind":"Conflict","code":"out_of_stock","details":"could not place the order","sku":"AB-1234"}` + "\n"),
+			expectedStatus:   http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+
+			test.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orders", http.NoBody))
+
+			if test.expectedStatus != rec.Code {
+				t.Errorf("expected status %d does not match received %d", test.expectedStatus, rec.Code)
+			}
+
+			if !bytes.Equal(test.expectedResponse, rec.Body.Bytes()) {
+				t.Errorf(
+					"response body does not match\nexpected: %s\nreceived: %s\n",
+					string(test.expectedResponse),
+					rec.Body.String(),
+				)
+			}
+		})
+	}
+}
+
+func TestProblemDetails(t *testing.T) {
+	tt := []struct {
+		name             string
+		handler          http.Handler
+		req              *http.Request
+		expectedResponse []byte
+		expectedStatus   int
+	}{
+		{
+			name:             "empty body",
+			handler:          jsonapi.Wrap(func(cmd *testCmd) {}, jsonapi.WithErrorFormat(jsonapi.ProblemErrors)),
+			req:              httptest.NewRequest(http.MethodPost, "/plans", http.NoBody),
+			expectedResponse: []byte(`{"type":"about:blank","title":"Bad Request","status":400,"detail":"Request body cannot be empty","instance":"/plans","code":"empty_body","kind":"Invalid Request"}` + "\n"),
+			expectedStatus:   http.StatusBadRequest,
+		},
+		{
+			name: "validation errors",
+			handler: jsonapi.Wrap(func(cmd *testCmd) {},
+				jsonapi.WithErrorFormat(jsonapi.ProblemErrors),
+				jsonapi.WithValidator(monthsValidator),
+			),
+			req:              httptest.NewRequest(http.MethodPost, "/plans", mustOpen(t, "valid.json")),
+			expectedResponse: []byte(`{"type":"about:blank","title":"Bad Request","status":400,"detail":"Validation errors","instance":"/plans","code":"validation_failed","errors":[{"field":"months","pointer":"/months","source":"body","code":"min_months","params":{"min":24},"value":12,"msg":"Plans must be of 24 months or more"}],"kind":"Invalid Request"}` + "\n"),
+			expectedStatus:   http.StatusBadRequest,
+		},
+		{
+			name: "panic",
+			handler: testAPI().Wrap(func() {
+				panic("something really bad has happened")
+			}, jsonapi.WithErrorFormat(jsonapi.ProblemErrors)),
+			req:              httptest.NewRequest(http.MethodGet, "/plans", http.NoBody),
+			expectedResponse: []byte(`{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"An unexpected error has occurred","instance":"/plans","code":"unexpected_error","kind":"Internal Error","reference":"f00dcafe"}` + "\n"),
+			expectedStatus:   http.StatusInternalServerError,
+		},
+		{
+			name: "domain error with its own type",
+			handler: jsonapi.Wrap(func() error {
+				return &outOfStockErr{sku: "AB-1234"}
+			}, jsonapi.WithErrorFormat(jsonapi.ProblemErrors)),
+			req:              httptest.NewRequest(http.MethodPost, "/orders", http.NoBody),
+			expectedResponse: []byte(`{"type":"https://example.com/problems/out-of-stock","title":"Conflict","status":409,"detail":"product AB-1234 is out of stock","instance":"/orders","code":"out_of_stock","kind":"Conflict","sku":"AB-1234"}` + "\n"),
+			expectedStatus:   http.StatusConflict,
+		},
+		{
+			name: "handler format is kept",
+			handler: jsonapi.Wrap(func() error {
+				return customErr(400, errors.New("server error"), "customer error")
+			}, jsonapi.WithErrorFormat(jsonapi.JSONErrors)),
+			req:              httptest.NewRequest(http.MethodPost, "/orders", http.NoBody),
+			expectedResponse: []byte(`{"status":400,"details":"customer error"}` + "\n"),
+			expectedStatus:   http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+
+			test.handler.ServeHTTP(rec, test.req)
+
+			if test.expectedStatus != rec.Code {
+				t.Errorf("expected status %d does not match received %d", test.expectedStatus, rec.Code)
+			}
+
+			if !bytes.Equal(test.expectedResponse, rec.Body.Bytes()) {
+				t.Errorf(
+					"response body does not match\nexpected: %s\nreceived: %s\n",
+					string(test.expectedResponse),
+					rec.Body.String(),
+				)
+			}
+		})
+	}
+}
+
+func TestProblemDetails_API(t *testing.T) {
+	api := &jsonapi.API{ErrorFormat: jsonapi.ProblemErrors}
+
+	rec := httptest.NewRecorder()
+	api.MethodNotAllowedHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/user/1234", http.NoBody))
+
+	expected := `{"type":"about:blank","title":"Method Not Allowed","status":405,"detail":"Method not allowed for POST /user/1234","instance":"/user/1234","code":"method_not_allowed","kind":"Method Not Allowed"}` + "\n"
+
+	if rec.Body.String() != expected {
+		t.Errorf("response body does not match\nexpected: %s\nreceived: %s\n", expected, rec.Body.String())
+	}
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("unexpected content type %s", ct)
+	}
+}
+
+func TestInternalErrors(t *testing.T) {
+	errDown := errors.New("pq: connection refused to 10.0.0.5")
+
+	var logged string
+	api := testAPI()
+	api.LogInternalError = func(req *http.Request, ref string, err error) {
+		chain := []string{ref}
+		for err != nil {
+			chain = append(chain, err.Error())
+			err = errors.Unwrap(err)
+		}
+
+		logged = chain[0] + " " + strings.Join(chain[1:], " | ")
+	}
+
+	tt := []struct {
+		name             string
+		handler          http.Handler
+		expectedResponse []byte
+		expectedLog      string
+	}{
+		{
+			name: "internal error",
+			handler: api.Wrap(func() error {
+				return fmt.Errorf("could not save plan: %w", errDown)
+			}),
+			expectedResponse: []byte(`{"status":500,"kind":"Internal Error","code":"unexpected_error","details":"An unexpected error has occurred","reference":"f00dcafe"}` + "\n"),
+			expectedLog:      "f00dcafe could not save plan: pq: connection refused to 10.0.0.5 | pq: connection refused to 10.0.0.5",
+		},
+		{
+			name: "internal error with code",
+			handler: api.Wrap(func() error {
+				return customErr(http.StatusServiceUnavailable, errDown, "database is down")
+			}),
+			expectedResponse: []byte(`{"status":503,"kind":"Internal Error","code":"unexpected_error","details":"An unexpected error has occurred","reference":"f00dcafe"}` + "\n"),
+			expectedLog:      "f00dcafe database is down | pq: connection refused to 10.0.0.5",
+		},
+		{
+			name: "public error",
+			handler: api.Wrap(func() error {
+				return jsonapi.Public(customErr(http.StatusServiceUnavailable, errDown, "database is down"))
+			}),
+			expectedResponse: []byte(`{"status":503,"details":"database is down","reference":"f00dcafe"}` + "\n"),
+			expectedLog:      "f00dcafe database is down | database is down | pq: connection refused to 10.0.0.5",
+		},
+		{
+			name: "mapped error with a message",
+			handler: api.Wrap(func() error {
+				return fmt.Errorf("could not save plan: %w", errDown)
+			}, jsonapi.WithErrorMapping(jsonapi.ErrorMapping{
+				Err:     errDown,
+				Status:  http.StatusServiceUnavailable,
+				Code:    "database_down",
+				Message: "Try again later",
+			})),
+			expectedResponse: []byte(`{"status":503,"code":"database_down","details":"Try again later","reference":"f00dcafe"}` + "\n"),
+			expectedLog:      "f00dcafe could not save plan: pq: connection refused to 10.0.0.5 | pq: connection refused to 10.0.0.5",
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			logged = ""
+
+			rec := httptest.NewRecorder()
+			test.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/plans", http.NoBody))
+
+			if !bytes.Equal(test.expectedResponse, rec.Body.Bytes()) {
+				t.Errorf(
+					"response body does not match\nexpected: %s\nreceived: %s\n",
+					string(test.expectedResponse),
+					rec.Body.String(),
+				)
+			}
+
+			if logged != test.expectedLog {
+				t.Errorf("log does not match\nexpected: %s\nreceived: %s\n", test.expectedLog, logged)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package jsonapi
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServerTimingHeader is the header the timings of a request are sent in
+const ServerTimingHeader = "Server-Timing"
+
+// WithServerTiming makes the handler measure the time spent in each of its phases, and send
+// them in the Server-Timing header, like:
+//
+//	Server-Timing: validate;dur=0.210, decode;dur=0.080, resolve;dur=0.100, call;dur=12.500, encode;dur=0.050, total;dur=13.020
+//
+// Durations are in milliseconds. Functions can add their own timings with AddTiming and
+// StartTiming. In debug mode, the timings are included in error responses too.
+//
+// The response is buffered, as the header is only known once the response is encoded.
+func WithServerTiming() OptsFn {
+	return func(h *JsonHandler) {
+		h.ServerTiming = true
+	}
+}
+
+// AddTiming adds a timing to the request the context belongs to. Timings with the same
+// name are added up. It does nothing if the handler does not send the Server-Timing header.
+//
+// Names must be valid tokens, like "db" or "cache-lookup".
+func AddTiming(ctx context.Context, name string, d time.Duration) {
+	timingsFrom(ctx).add(name, d)
+}
+
+// StartTiming starts measuring a timing, and returns the function that stops it. See AddTiming.
+//
+//	defer jsonapi.StartTiming(ctx, "db")()
+func StartTiming(ctx context.Context, name string) (stop func()) {
+	start := time.Now()
+
+	return func() {
+		AddTiming(ctx, name, time.Since(start))
+	}
+}
+
+func (h *JsonHandler) serverTiming() bool {
+	return h.ServerTiming || h.api.ServerTiming
+}
+
+type timingsKey struct{}
+
+type timing struct {
+	name string
+	dur  time.Duration
+}
+
+// serverTimings are the timings of a request. A nil *serverTimings ignores them.
+type serverTimings struct {
+	mu      sync.Mutex
+	entries []timing
+}
+
+func withTimings(req *http.Request) (*http.Request, *serverTimings) {
+	t := &serverTimings{}
+
+	return req.WithContext(context.WithValue(req.Context(), timingsKey{}, t)), t
+}
+
+func timingsFrom(ctx context.Context) *serverTimings {
+	t, _ := ctx.Value(timingsKey{}).(*serverTimings)
+
+	return t
+}
+
+func (t *serverTimings) add(name string, d time.Duration) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i := range t.entries {
+		if t.entries[i].name == name {
+			t.entries[i].dur += d
+			return
+		}
+	}
+
+	t.entries = append(t.entries, timing{name: name, dur: d})
+}
+
+func (t *serverTimings) list() []timing {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]timing(nil), t.entries...)
+}
+
+// String renders the timings as the value of a Server-Timing header
+func (t *serverTimings) String() string {
+	entries := t.list()
+	metrics := make([]string, 0, len(entries))
+
+	for _, e := range entries {
+		metrics = append(metrics, e.name+";dur="+milliseconds(e.dur))
+	}
+
+	return strings.Join(metrics, ", ")
+}
+
+func milliseconds(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+// timedSpan adds the duration of a span to the timings of the request when it ends
+type timedSpan struct {
+	Span
+	timings *serverTimings
+	name    string
+	start   time.Time
+}
+
+func (s *timedSpan) End() {
+	s.timings.add(s.name, time.Since(s.start))
+	s.Span.End()
+}
+
+// timingWriter buffers the response, so the Server-Timing header can be set once it is encoded
+type timingWriter struct {
+	bufferedWriter
+	timings *serverTimings
+}
+
+// flush sends the buffered response, with the timings
+func (w *timingWriter) flush(total time.Duration) {
+	w.timings.add("total", total)
+	w.Header().Set(ServerTimingHeader, w.timings.String())
+	w.send()
+}
+
+// timingEntry is a timing in the debug info of an error response
+type timingEntry struct {
+	Name string  `json:"name"`
+	Dur  float64 `json:"dur"` // In milliseconds
+}
+
+func debugTimings(req *http.Request) []timingEntry {
+	var entries []timingEntry
+
+	for _, t := range timingsFrom(req.Context()).list() {
+		entries = append(entries, timingEntry{Name: t.name, Dur: float64(t.dur) / float64(time.Millisecond)})
+	}
+
+	return entries
+}
@@ -0,0 +1,92 @@
+package jsonapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/mnavarrocarter/jsonapi"
+)
+
+func TestWithServerTiming(t *testing.T) {
+	handler := jsonapi.Wrap(func(ctx context.Context, cmd *testCmd) *testResp {
+		defer jsonapi.StartTiming(ctx, "db")()
+		jsonapi.AddTiming(ctx, "cache", time.Millisecond)
+
+		return &testResp{Msg: cmd.Name}
+	}, jsonapi.WithSchema(mustOpen(t, "schema.json")), jsonapi.WithServerTiming())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/plans", mustOpen(t, "valid.json")))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d does not match received %d", http.StatusOK, rec.Code)
+	}
+
+	if body := rec.Body.String(); body != `{"msg":"Finance Plan 01"}`+"\n" {
+		t.Errorf("unexpected body %s", body)
+	}
+
+	expected := regexp.MustCompile(`^decode;dur=\d+\.\d{3}, validate;dur=\d+\.\d{3}, resolve;dur=\d+\.\d{3}, cache;dur=1\.000, db;dur=\d+\.\d{3}, call;dur=\d+\.\d{3}, encode;dur=\d+\.\d{3}, total;dur=\d+\.\d{3}$`)
+	if header := rec.Header().Get("Server-Timing"); !expected.MatchString(header) {
+		t.Errorf("unexpected Server-Timing header %q", header)
+	}
+}
+
+func TestWithServerTiming_Debug(t *testing.T) {
+	handler := jsonapi.Wrap(func(ctx context.Context) error {
+		jsonapi.AddTiming(ctx, "db", 2*time.Millisecond)
+
+		return errConflict
+	}, jsonapi.WithServerTiming(), jsonapi.WithDebug(), jsonapi.WithErrorMapping(conflictMapping))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/plans", http.NoBody))
+
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status %d does not match received %d", http.StatusConflict, rec.Code)
+	}
+
+	var body struct {
+		Debug struct {
+			Timings []struct {
+				Name string  `json:"name"`
+				Dur  float64 `json:"dur"`
+			} `json:"timings"`
+		} `json:"debug"`
+	}
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, timing := range body.Debug.Timings {
+		names = append(names, timing.Name)
+	}
+
+	if len(names) != 4 || names[0] != "validate" || names[1] != "resolve" || names[2] != "db" || names[3] != "call" {
+		t.Errorf("unexpected timings %v", names)
+	}
+}
+
+func TestWithoutServerTiming(t *testing.T) {
+	handler := jsonapi.Wrap(func(ctx context.Context) {
+		jsonapi.AddTiming(ctx, "db", time.Millisecond)
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/plans", http.NoBody))
+
+	if header := rec.Header().Get("Server-Timing"); header != "" {
+		t.Errorf("unexpected Server-Timing header %q", header)
+	}
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected status %d does not match received %d", http.StatusNoContent, rec.Code)
+	}
+}
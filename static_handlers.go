@@ -6,15 +6,29 @@ import (
 )
 
 var NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-	HandleError(w, req, &apiError{
-		code: http.StatusNotFound,
-		msg:  fmt.Sprintf("No handler found for %s %s", req.Method, req.URL.Path),
-	})
+	HandleError(w, req, notFoundError(req))
 })
 
 var MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-	HandleError(w, req, &apiError{
-		code: http.StatusMethodNotAllowed,
-		msg:  fmt.Sprintf("Method not allowed for %s %s", req.Method, req.URL.Path),
-	})
+	HandleError(w, req, methodNotAllowedError(req))
 })
+
+func notFoundError(req *http.Request) error {
+	return &apiError{
+		code:    http.StatusNotFound,
+		msg:     fmt.Sprintf("No handler found for %s %s", req.Method, req.URL.Path),
+		errCode: "not_found",
+		kind:    KindNotFound,
+		params:  map[string]interface{}{"method": req.Method, "path": req.URL.Path},
+	}
+}
+
+func methodNotAllowedError(req *http.Request) error {
+	return &apiError{
+		code:    http.StatusMethodNotAllowed,
+		msg:     fmt.Sprintf("Method not allowed for %s %s", req.Method, req.URL.Path),
+		errCode: "method_not_allowed",
+		kind:    KindMethodNotAllowed,
+		params:  map[string]interface{}{"method": req.Method, "path": req.URL.Path},
+	}
+}
@@ -0,0 +1,157 @@
+package jsonapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
+
+// RequestTimeoutHeader is the header clients can set the timeout of their requests in,
+// in seconds. See WithClientTimeout.
+const RequestTimeoutHeader = "Request-Timeout"
+
+// StatusClientClosedRequest is the status code recorded, in logs and metrics, for the
+// requests whose client went away before the response was sent. Nothing is sent to them.
+const StatusClientClosedRequest = 499
+
+// WithTimeout sets the time the handler gives the function to return.
+//
+// The function is given a context with the deadline. When it expires, the handler replies
+// with a 503 error with the "timeout" code, and the result of the function is discarded.
+// Map context.DeadlineExceeded to use another status code, like 504.
+//
+// The function runs in a goroutine of its own, which cannot be stopped. Functions must
+// honor the context, and return when it is done: the goroutine of a function that ignores
+// it keeps running after the error has been sent, along with whatever it does, like the
+// queries to a database, until the function returns.
+func WithTimeout(d time.Duration) OptsFn {
+	return func(h *JsonHandler) {
+		h.Timeout = d
+	}
+}
+
+// WithClientTimeout makes the handler honor the timeout clients set in the Request-Timeout
+// header, as long as it is shorter than the timeout of the handler. See WithTimeout.
+func WithClientTimeout() OptsFn {
+	return func(h *JsonHandler) {
+		h.ClientTimeout = true
+	}
+}
+
+// timeout returns the timeout for a request, zero if there is none
+func (h *JsonHandler) timeout(req *http.Request) time.Duration {
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = h.api.Timeout
+	}
+
+	if timeout == 0 || !(h.ClientTimeout || h.api.ClientTimeout) {
+		return timeout
+	}
+
+	seconds, err := strconv.ParseFloat(req.Header.Get(RequestTimeoutHeader), 64)
+	if err != nil || seconds <= 0 {
+		return timeout
+	}
+
+	if d := time.Duration(seconds * float64(time.Second)); d < timeout {
+		return d
+	}
+
+	return timeout
+}
+
+// withTimeout sets the deadline of the request, if the handler has a timeout
+func (h *JsonHandler) withTimeout(req *http.Request) (*http.Request, context.CancelFunc) {
+	timeout := h.timeout(req)
+	if timeout == 0 {
+		return req, func() {}
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+
+	return req.WithContext(ctx), cancel
+}
+
+// goroutinePanic carries a panic in the goroutine of a function to the goroutine of the
+// handler, with the stack of the function.
+type goroutinePanic struct {
+	v     interface{}
+	stack []byte
+}
+
+type callResult struct {
+	out    interface{}
+	err    error
+	panick *goroutinePanic
+}
+
+// call calls the function. When the request has a deadline, the function runs in its own
+// goroutine, and a timeout error is returned if it does not return in time.
+func (h *JsonHandler) call(req *http.Request, args []reflect.Value) (interface{}, error) {
+	ctx := req.Context()
+	if _, ok := ctx.Deadline(); !ok {
+		return h.fn.call(args)
+	}
+
+	// Buffered, so the goroutine ends when the function returns, even after a timeout. It is
+	// not stopped before that, see WithTimeout.
+	results := make(chan callResult, 1)
+	returned := make(chan struct{})
+
+	go func() {
+		var res callResult
+
+		defer func() {
+			if r := recover(); r != nil {
+				res.panick = &goroutinePanic{v: r, stack: debug.Stack()}
+			}
+
+			results <- res
+			close(returned)
+		}()
+
+		res.out, res.err = h.fn.call(args)
+	}()
+
+	select {
+	case res := <-results:
+		if res.panick != nil && h.SkipPanic {
+			panic(res.panick.v)
+		}
+
+		if res.panick != nil {
+			panic(res.panick)
+		}
+
+		if res.err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, timeoutError(ctx.Err())
+		}
+
+		return res.out, res.err
+	case <-ctx.Done():
+		// The function is still running, so what it does is not over yet
+		stateFrom(req).running = returned
+
+		return nil, timeoutError(ctx.Err())
+	}
+}
+
+func timeoutError(err error) error {
+	return &apiError{
+		code:    http.StatusServiceUnavailable,
+		msg:     "The request took too long to be served",
+		errCode: "timeout",
+		kind:    KindTimeout,
+		prev:    err,
+	}
+}
+
+// clientGone tells whether the client of the request went away
+func clientGone(req *http.Request) bool {
+	return originalContext(req.Context()).Err() != nil
+}
@@ -0,0 +1,169 @@
+package jsonapi_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mnavarrocarter/jsonapi"
+)
+
+func TestWithTimeout(t *testing.T) {
+	slow := func(ctx context.Context) *testResp {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+
+		return &testResp{Msg: "too late"}
+	}
+
+	honoring := func(ctx context.Context) (*testResp, error) {
+		<-ctx.Done()
+
+		return nil, ctx.Err()
+	}
+
+	fast := func(ctx context.Context) *testResp {
+		return &testResp{Msg: "success"}
+	}
+
+	api := testAPI()
+	api.Timeout = 10 * time.Millisecond
+
+	timeoutResponse := []byte(`{"status":503,"kind":"Timeout","code":"timeout","details":"The request took too long to be served","reference":"f00dcafe"}` + "\n")
+
+	tt := []struct {
+		name             string
+		handler          http.Handler
+		header           string
+		expectedResponse []byte
+		expectedStatus   int
+	}{
+		{
+			name:             "slow function",
+			handler:          testAPI().Wrap(slow, jsonapi.WithTimeout(10*time.Millisecond)),
+			expectedResponse: timeoutResponse,
+			expectedStatus:   http.StatusServiceUnavailable,
+		},
+		{
+			name:             "function honoring the context",
+			handler:          testAPI().Wrap(honoring, jsonapi.WithTimeout(10*time.Millisecond)),
+			expectedResponse: timeoutResponse,
+			expectedStatus:   http.StatusServiceUnavailable,
+		},
+		{
+			name:             "fast function",
+			handler:          testAPI().Wrap(fast, jsonapi.WithTimeout(time.Minute)),
+			expectedResponse: []byte(`{"msg":"success"}` + "\n"),
+			expectedStatus:   http.StatusOK,
+		},
+		{
+			name:             "client timeout",
+			handler:          testAPI().Wrap(honoring, jsonapi.WithTimeout(time.Minute), jsonapi.WithClientTimeout()),
+			header:           "0.01",
+			expectedResponse: timeoutResponse,
+			expectedStatus:   http.StatusServiceUnavailable,
+		},
+		{
+			name:             "client timeout capped by the server",
+			handler:          testAPI().Wrap(honoring, jsonapi.WithTimeout(10*time.Millisecond), jsonapi.WithClientTimeout()),
+			header:           "3600",
+			expectedResponse: timeoutResponse,
+			expectedStatus:   http.StatusServiceUnavailable,
+		},
+		{
+			name:             "client timeout ignored",
+			handler:          testAPI().Wrap(fast, jsonapi.WithTimeout(time.Minute)),
+			header:           "0.000001",
+			expectedResponse: []byte(`{"msg":"success"}` + "\n"),
+			expectedStatus:   http.StatusOK,
+		},
+		{
+			name: "mapped timeout",
+			handler: testAPI().Wrap(slow, jsonapi.WithTimeout(10*time.Millisecond), jsonapi.WithErrorMapping(jsonapi.ErrorMapping{
+				Err:    context.DeadlineExceeded,
+				Status: http.StatusGatewayTimeout,
+			})),
+			expectedResponse: []byte(`{"status":504,"kind":"Timeout","code":"timeout","details":"The request took too long to be served","reference":"f00dcafe"}` + "\n"),
+			expectedStatus:   http.StatusGatewayTimeout,
+		},
+		{
+			name:             "api",
+			handler:          api.Wrap(slow),
+			expectedResponse: timeoutResponse,
+			expectedStatus:   http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/plans", http.NoBody)
+
+			if test.header != "" {
+				req.Header.Set(jsonapi.RequestTimeoutHeader, test.header)
+			}
+
+			test.handler.ServeHTTP(rec, req)
+
+			if test.expectedStatus != rec.Code {
+				t.Errorf("expected status %d does not match received %d", test.expectedStatus, rec.Code)
+			}
+
+			if !bytes.Equal(test.expectedResponse, rec.Body.Bytes()) {
+				t.Errorf(
+					"response body does not match\nexpected: %s\nreceived: %s\n",
+					string(test.expectedResponse),
+					rec.Body.String(),
+				)
+			}
+		})
+	}
+}
+
+func TestWithTimeout_Panic(t *testing.T) {
+	var stack []byte
+
+	handler := jsonapi.Wrap(func() {
+		panic("something really bad has happened")
+	}, jsonapi.WithTimeout(time.Minute), jsonapi.WithPanicReporter(jsonapi.PanicReporterFunc(func(req *http.Request, v interface{}, s []byte) {
+		stack = s
+	})))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/plans", http.NoBody))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d does not match received %d", http.StatusInternalServerError, rec.Code)
+	}
+
+	if !bytes.Contains(stack, []byte("timeout_test.go")) {
+		t.Errorf("expected the stack of the function, got:\n%s", stack)
+	}
+}
+
+func TestClientGone(t *testing.T) {
+	buf := &bytes.Buffer{}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	handler := jsonapi.Wrap(func() *testResp {
+		// The client goes away while the function is running
+		cancel()
+
+		return &testResp{Msg: "nobody is listening"}
+	}, jsonapi.WithLogger(newTestLogger(buf)))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/plans", http.NoBody).WithContext(ctx))
+
+	if rec.Body.Len() != 0 {
+		t.Errorf("expected no response, got: %s", rec.Body.String())
+	}
+
+	if !strings.Contains(buf.String(), "status=499") {
+		t.Errorf("expected the request to be logged as closed by the client, got: %s", buf.String())
+	}
+}
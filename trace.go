@@ -0,0 +1,242 @@
+package jsonapi
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The headers of the W3C Trace Context
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// A Tracer opens the spans of the requests served by a handler.
+//
+// The handler opens a span for the whole request, named like the handler, and child spans
+// named "validate", "resolve", "call" and "encode" for each of its stages.
+type Tracer interface {
+	// Start opens a span, child of the span in ctx, if any. The returned context holds the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// A Span is an operation in a trace
+type Span interface {
+	SpanContext() SpanContext
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID identifies a span in a trace
+type SpanID [8]byte
+
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext is the part of a span that is propagated across services
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	State   string // The vendor specific state, from the tracestate header
+}
+
+// IsValid tells whether the span context identifies a span
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent renders the span context as the value of a traceparent header
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent parses the value of a traceparent header, like
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func ParseTraceparent(v string) (SpanContext, bool) {
+	var sc SpanContext
+
+	v = strings.TrimSpace(v)
+	if len(v) < 55 || !isLowerHex(v[:2]) || v[:2] == "ff" {
+		return sc, false
+	}
+
+	// Later versions can add fields, but the first ones are kept
+	if (v[:2] == "00" && len(v) != 55) || (len(v) > 55 && v[55] != '-') {
+		return sc, false
+	}
+
+	if v[2] != '-' || v[35] != '-' || v[52] != '-' {
+		return sc, false
+	}
+
+	traceID, spanID, flags := v[3:35], v[36:52], v[53:55]
+	if !isLowerHex(traceID) || !isLowerHex(spanID) || !isLowerHex(flags) {
+		return sc, false
+	}
+
+	_, _ = hex.Decode(sc.TraceID[:], []byte(traceID))
+	_, _ = hex.Decode(sc.SpanID[:], []byte(spanID))
+
+	var f [1]byte
+	_, _ = hex.Decode(f[:], []byte(flags))
+	sc.Sampled = f[0]&1 == 1
+
+	return sc, sc.IsValid()
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ExtractTraceContext returns the span context propagated in the headers of a request
+func ExtractTraceContext(header http.Header) (SpanContext, bool) {
+	sc, ok := ParseTraceparent(header.Get(TraceparentHeader))
+	if !ok {
+		return sc, false
+	}
+
+	sc.State = strings.TrimSpace(strings.Join(header.Values(TracestateHeader), ","))
+
+	return sc, true
+}
+
+// InjectTraceContext propagates the active span of ctx in the headers of an outgoing request
+func InjectTraceContext(ctx context.Context, header http.Header) {
+	sc := SpanFromContext(ctx).SpanContext()
+	if !sc.IsValid() {
+		return
+	}
+
+	header.Set(TraceparentHeader, sc.Traceparent())
+
+	if sc.State != "" {
+		header.Set(TracestateHeader, sc.State)
+	}
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns a copy of ctx holding the span
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the active span of ctx. If there is none, it returns a span that
+// does nothing.
+func SpanFromContext(ctx context.Context) Span {
+	if span, ok := ctx.Value(spanKey{}).(Span); ok {
+		return span
+	}
+
+	return noopSpan{}
+}
+
+// NoopTracer is a Tracer that records nothing. It keeps the span of the caller active, so
+// it is still propagated.
+type NoopTracer struct{}
+
+func (NoopTracer) Start(ctx context.Context, _ string) (context.Context, Span) {
+	return ctx, noopSpan{sc: SpanFromContext(ctx).SpanContext()}
+}
+
+type noopSpan struct {
+	sc SpanContext
+}
+
+func (s noopSpan) SpanContext() SpanContext {
+	return s.sc
+}
+
+func (noopSpan) SetAttribute(string, interface{}) {}
+
+func (noopSpan) RecordError(error) {}
+
+func (noopSpan) End() {}
+
+// WithTracer sets the tracer of the handler
+func WithTracer(tracer Tracer) OptsFn {
+	return func(h *JsonHandler) {
+		h.Tracer = tracer
+	}
+}
+
+func (h *JsonHandler) tracer() Tracer {
+	if h.Tracer != nil {
+		return h.Tracer
+	}
+
+	if h.api.Tracer != nil {
+		return h.api.Tracer
+	}
+
+	return NoopTracer{}
+}
+
+// startSpan opens the span of a request, as a child of the span propagated by the caller
+func (h *JsonHandler) startSpan(req *http.Request) (*http.Request, Span) {
+	ctx := req.Context()
+
+	if sc, ok := ExtractTraceContext(req.Header); ok {
+		ctx = ContextWithSpan(ctx, noopSpan{sc: sc})
+	}
+
+	ctx, span := h.tracer().Start(ctx, h.name())
+	span.SetAttribute("http.request.method", req.Method)
+	span.SetAttribute("url.path", req.URL.Path)
+
+	return req.WithContext(ctx), span
+}
+
+// startChildSpan opens the span of a stage of the request. When the handler sends the
+// Server-Timing header, the span is timed too.
+func (h *JsonHandler) startChildSpan(req *http.Request, name string) Span {
+	_, span := h.startChildSpanContext(req, name)
+
+	return span
+}
+
+// startChildSpanContext opens the span of a stage of the request, and returns the context
+// holding it, for the stages that run code of the caller, like the function
+func (h *JsonHandler) startChildSpanContext(req *http.Request, name string) (context.Context, Span) {
+	ctx, span := h.tracer().Start(req.Context(), name)
+
+	if timings := timingsFrom(req.Context()); timings != nil {
+		return ctx, &timedSpan{Span: span, timings: timings, name: name, start: time.Now()}
+	}
+
+	return ctx, span
+}
@@ -0,0 +1,98 @@
+package jsonapi
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+// A TraceRecorder is a Tracer that keeps the spans in memory, for tests
+type TraceRecorder struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+// A RecordedSpan is a span recorded by a TraceRecorder
+type RecordedSpan struct {
+	Name        string
+	SpanContext SpanContext
+	Parent      SpanID // Zero for the root span of a trace
+	Attributes  map[string]interface{}
+	Errors      []error
+	Start       time.Time
+	End         time.Time
+
+	recorder *TraceRecorder
+}
+
+// NewTraceRecorder makes an empty TraceRecorder
+func NewTraceRecorder() *TraceRecorder {
+	return &TraceRecorder{}
+}
+
+func (r *TraceRecorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent := SpanFromContext(ctx).SpanContext()
+
+	rec := &RecordedSpan{
+		Name:       name,
+		Attributes: make(map[string]interface{}),
+		Start:      time.Now(),
+		recorder:   r,
+	}
+
+	if parent.IsValid() {
+		rec.SpanContext = parent
+		rec.Parent = parent.SpanID
+	} else {
+		_, _ = rand.Read(rec.SpanContext.TraceID[:])
+		rec.SpanContext.Sampled = true
+	}
+
+	_, _ = rand.Read(rec.SpanContext.SpanID[:])
+
+	span := &recordingSpan{rec}
+
+	return ContextWithSpan(ctx, span), span
+}
+
+// Spans returns the spans that have ended, in the order they ended
+func (r *TraceRecorder) Spans() []*RecordedSpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*RecordedSpan(nil), r.spans...)
+}
+
+// Reset forgets the recorded spans
+func (r *TraceRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.spans = nil
+}
+
+type recordingSpan struct {
+	*RecordedSpan
+}
+
+func (s *recordingSpan) SpanContext() SpanContext {
+	return s.RecordedSpan.SpanContext
+}
+
+func (s *recordingSpan) SetAttribute(key string, value interface{}) {
+	s.Attributes[key] = value
+}
+
+func (s *recordingSpan) RecordError(err error) {
+	s.Errors = append(s.Errors, err)
+}
+
+func (s *recordingSpan) End() {
+	s.RecordedSpan.End = time.Now()
+
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+
+	s.recorder.spans = append(s.recorder.spans, s.RecordedSpan)
+}
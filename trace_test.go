@@ -0,0 +1,140 @@
+package jsonapi_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mnavarrocarter/jsonapi"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tt := []struct {
+		name            string
+		traceparent     string
+		expectedOk      bool
+		expectedSampled bool
+	}{
+		{name: "sampled", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", expectedOk: true, expectedSampled: true},
+		{name: "not sampled", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", expectedOk: true},
+		{name: "future version", traceparent: "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", expectedOk: true, expectedSampled: true},
+		{name: "extra fields in version 00", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+		{name: "invalid version", traceparent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "uppercase", traceparent: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		{name: "zero trace id", traceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{name: "zero span id", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		{name: "wrong separator", traceparent: "00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "empty"},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			sc, ok := jsonapi.ParseTraceparent(test.traceparent)
+
+			if test.expectedOk != ok {
+				t.Fatalf("expected ok %v does not match received %v", test.expectedOk, ok)
+			}
+
+			if !ok {
+				return
+			}
+
+			if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" {
+				t.Errorf("unexpected span context %s", sc.Traceparent())
+			}
+
+			if test.expectedSampled != sc.Sampled {
+				t.Errorf("expected sampled %v does not match received %v", test.expectedSampled, sc.Sampled)
+			}
+		})
+	}
+}
+
+func TestWithTracer(t *testing.T) {
+	recorder := jsonapi.NewTraceRecorder()
+	outgoing := http.Header{}
+
+	handler := jsonapi.Wrap(func(ctx context.Context) error {
+		jsonapi.InjectTraceContext(ctx, outgoing)
+
+		return errConflict
+	}, jsonapi.WithName("plans"), jsonapi.WithTracer(recorder), jsonapi.WithErrorMapping(conflictMapping))
+
+	req := httptest.NewRequest(http.MethodPost, "/plans", http.NoBody)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("tracestate", "vendor=value")
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Spans()
+
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+
+	expectedNames := []string{"validate", "resolve", "call", "encode", "plans"}
+	if len(names) != len(expectedNames) {
+		t.Fatalf("expected spans %v do not match received %v", expectedNames, names)
+	}
+
+	for i := range names {
+		if names[i] != expectedNames[i] {
+			t.Fatalf("expected spans %v do not match received %v", expectedNames, names)
+		}
+	}
+
+	root := spans[len(spans)-1]
+
+	if root.SpanContext.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected the trace of the caller, got %s", root.SpanContext.TraceID)
+	}
+
+	if root.Parent.String() != "00f067aa0ba902b7" {
+		t.Errorf("expected the span of the caller as parent, got %s", root.Parent)
+	}
+
+	if root.Attributes["http.response.status_code"] != http.StatusConflict {
+		t.Errorf("expected the status code, got %v", root.Attributes["http.response.status_code"])
+	}
+
+	if len(root.Errors) != 1 || root.Errors[0] != errConflict {
+		t.Errorf("expected the error to be recorded, got %v", root.Errors)
+	}
+
+	for _, span := range spans[:len(spans)-1] {
+		if span.Parent != root.SpanContext.SpanID {
+			t.Errorf("expected span %s to be a child of the request span", span.Name)
+		}
+	}
+
+	if spans[1].Attributes["argument.type"] != "context.Context" {
+		t.Errorf("expected the type of the argument, got %v", spans[1].Attributes["argument.type"])
+	}
+
+	if tp := outgoing.Get("traceparent"); tp != spans[2].SpanContext.Traceparent() {
+		t.Errorf("expected the call span to be propagated, got %q", tp)
+	}
+
+	if ts := outgoing.Get("tracestate"); ts != "vendor=value" {
+		t.Errorf("expected the tracestate to be propagated, got %q", ts)
+	}
+}
+
+func TestNoopTracer(t *testing.T) {
+	outgoing := http.Header{}
+
+	handler := jsonapi.Wrap(func(ctx context.Context) {
+		jsonapi.InjectTraceContext(ctx, outgoing)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/plans", http.NoBody)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if tp := outgoing.Get("traceparent"); tp != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("expected the incoming trace to be propagated, got %q", tp)
+	}
+}
@@ -3,6 +3,7 @@ package jsonapi
 import (
 	"errors"
 	"net/http"
+	"strings"
 )
 
 var ErrValidation = errors.New("validation error")
@@ -19,8 +20,54 @@ type RequestValidator interface {
 	Validate(req *http.Request) ([]*ErrorItem, error)
 }
 
+// The sources an ErrorItem can come from
+const (
+	SourceBody   = "body"
+	SourceQuery  = "query"
+	SourcePath   = "path"
+	SourceHeader = "header"
+)
+
+// An ErrorItem describes a single validation error
 type ErrorItem struct {
-	Field string      `json:"field"`
-	Value interface{} `json:"value"`
-	Msg   string      `json:"msg"`
+	Field   string                 `json:"field"`             // The field, in dot notation
+	Pointer string                 `json:"pointer,omitempty"` // The RFC 6901 JSON Pointer to the field
+	Source  string                 `json:"source,omitempty"`  // Where the field comes from: body, query, path or header
+	Code    string                 `json:"code,omitempty"`    // A stable code for the rule that failed, like min_length
+	Params  map[string]interface{} `json:"params,omitempty"`  // The parameters of the rule, like {"min": 2}
+	Value   interface{}            `json:"value"`
+	Msg     string                 `json:"msg"`
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// JSONPointer builds an RFC 6901 JSON Pointer from its reference tokens
+//
+// JSONPointer("items", "0", "name") yields "/items/0/name"
+func JSONPointer(tokens ...string) string {
+	var b strings.Builder
+
+	for _, token := range tokens {
+		b.WriteString("/")
+		b.WriteString(pointerEscaper.Replace(token))
+	}
+
+	return b.String()
+}
+
+// ValidationError is an error made of the validation errors of a request.
+//
+// Argument resolvers and functions return it to get the same 400 response validators get
+// for their ErrorItems.
+type ValidationError struct {
+	Items []*ErrorItem
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Items))
+	for _, item := range e.Items {
+		msgs = append(msgs, item.Field+": "+item.Msg)
+	}
+
+	return "validation failed: " + strings.Join(msgs, ", ")
 }
@@ -0,0 +1,95 @@
+package jsonapi
+
+import (
+	"net/http"
+)
+
+// ValidationMode defines how a ValidatorChain runs its validators
+type ValidationMode int
+
+const (
+	// CollectAll runs every validator and merges all the errors they report
+	CollectAll ValidationMode = iota
+	// FailFast stops at the first validator that reports errors
+	FailFast
+)
+
+// A ValidatorChain runs several validators in order and merges the ErrorItems they report
+// into a single response.
+//
+// If any of the validators fails with an error, the chain stops and returns that error.
+type ValidatorChain struct {
+	Validators []RequestValidator
+	Mode       ValidationMode
+}
+
+func (c *ValidatorChain) Validate(req *http.Request) ([]*ErrorItem, error) {
+	var items []*ErrorItem
+
+	for _, v := range c.Validators {
+		errs, err := v.Validate(req)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, errs...)
+
+		if len(items) != 0 && c.Mode == FailFast {
+			break
+		}
+	}
+
+	return items, nil
+}
+
+// ValidatorFunc adapts a function into a RequestValidator
+type ValidatorFunc func(req *http.Request) ([]*ErrorItem, error)
+
+func (fn ValidatorFunc) Validate(req *http.Request) ([]*ErrorItem, error) {
+	return fn(req)
+}
+
+// WithValidator adds validators to the handler.
+//
+// Validators are added to the handler's ValidatorChain, so they run after any validator
+// added before, like the ones from WithSchema.
+func WithValidator(validators ...RequestValidator) OptsFn {
+	return func(h *JsonHandler) {
+		c := validatorChain(h)
+		c.Validators = append(c.Validators, validators...)
+	}
+}
+
+// WithValidationMode sets the ValidationMode of the handler's ValidatorChain
+func WithValidationMode(mode ValidationMode) OptsFn {
+	return func(h *JsonHandler) {
+		validatorChain(h).Mode = mode
+	}
+}
+
+// validatorChain returns the ValidatorChain of the handler, creating it if needed
+//
+// The validator the handler had is kept as the first link of the chain, unless it is the
+// no-op validator of the Defaults. The chain of the API is shared by all its handlers, so
+// the handler gets a copy of it.
+func validatorChain(h *JsonHandler) *ValidatorChain {
+	if c, ok := h.RequestValidator.(*ValidatorChain); ok {
+		if c != h.api.RequestValidator {
+			return c
+		}
+
+		c = &ValidatorChain{Validators: append([]RequestValidator(nil), c.Validators...), Mode: c.Mode}
+		h.RequestValidator = c
+
+		return c
+	}
+
+	c := &ValidatorChain{}
+	if h.RequestValidator != nil && h.RequestValidator != RequestValidator(Defaults) {
+		c.Validators = append(c.Validators, h.RequestValidator)
+	}
+
+	h.RequestValidator = c
+
+	return c
+}
@@ -0,0 +1,125 @@
+package jsonapi_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mnavarrocarter/jsonapi"
+)
+
+var monthsValidator = jsonapi.ValidatorFunc(func(req *http.Request) ([]*jsonapi.ErrorItem, error) {
+	return []*jsonapi.ErrorItem{{
+		Field:   "months",
+		Pointer: jsonapi.JSONPointer("months"),
+		Source:  jsonapi.SourceBody,
+		Code:    "min_months",
+		Params:  map[string]interface{}{"min": 24},
+		Value:   12,
+		Msg:     "Plans must be of 24 months or more",
+	}}, nil
+})
+
+var depositValidator = jsonapi.ValidatorFunc(func(req *http.Request) ([]*jsonapi.ErrorItem, error) {
+	return []*jsonapi.ErrorItem{{Field: "deposit", Value: true, Msg: "Deposits are not allowed"}}, nil
+})
+
+func TestWithValidator(t *testing.T) {
+	tt := []struct {
+		name             string
+		opts             []jsonapi.OptsFn
+		body             string
+		expectedResponse []byte
+		expectedStatus   int
+	}{
+		{
+			name: "collects the errors of every validator",
+			opts: []jsonapi.OptsFn{
+				jsonapi.WithSchema(mustOpen(t, "schema.json")),
+				jsonapi.WithValidator(monthsValidator, depositValidator),
+			},
+			body:             "invalid.json",
+			expectedResponse: []byte(`{"status":400,"kind":"Invalid Request","code":"validation_failed","details":"Validation errors","errors":[{"field":"id","pointer":"/id","source":"body","code":"format","params":{"format":"uuid"},"value":"3f2476fd2b-270f-4baa-81c9-01e91fc87fd3","msg":"Does not match format 'uuid'"},{"field":"name","pointer":"/name","source":"body","code":"min_length","params":{"min":1},"value":"","msg":"String length must be greater than or equal to 1"},{"field":"months","pointer":"/months","source":"body","code":"min_months","params":{"min":24},"value":12,"msg":"Plans must be of 24 months or more"},{"field":"deposit","value":true,"msg":"Deposits are not allowed"}]}` + "\n"),
+			expectedStatus:   http.StatusBadRequest,
+		},
+		{
+			name: "fails fast",
+			opts: []jsonapi.OptsFn{
+				jsonapi.WithSchema(mustOpen(t, "schema.json")),
+				jsonapi.WithValidator(monthsValidator, depositValidator),
+				jsonapi.WithValidationMode(jsonapi.FailFast),
+			},
+			body:             "invalid.json",
+			expectedResponse: []byte(`{"status":400,"kind":"Invalid Request","code":"validation_failed","details":"Validation errors","errors":[{"field":"id","pointer":"/id","source":"body","code":"format","params":{"format":"uuid"},"value":"3f2476fd2b-270f-4baa-81c9-01e91fc87fd3","msg":"Does not match format 'uuid'"},{"field":"name","pointer":"/name","source":"body","code":"min_length","params":{"min":1},"value":"","msg":"String length must be greater than or equal to 1"}]}` + "\n"),
+			expectedStatus:   http.StatusBadRequest,
+		},
+		{
+			name: "runs the validators after a valid schema",
+			opts: []jsonapi.OptsFn{
+				jsonapi.WithValidator(monthsValidator),
+				jsonapi.WithSchema(mustOpen(t, "schema.json")),
+				jsonapi.WithValidationMode(jsonapi.FailFast),
+			},
+			body:             "valid.json",
+			expectedResponse: []byte(`{"status":400,"kind":"Invalid Request","code":"validation_failed","details":"Validation errors","errors":[{"field":"months","pointer":"/months","source":"body","code":"min_months","params":{"min":24},"value":12,"msg":"Plans must be of 24 months or more"}]}` + "\n"),
+			expectedStatus:   http.StatusBadRequest,
+		},
+		{
+			name: "stops on validator errors",
+			opts: []jsonapi.OptsFn{
+				jsonapi.WithValidator(jsonapi.ValidatorFunc(func(req *http.Request) ([]*jsonapi.ErrorItem, error) {
+					return nil, errors.New("database is down")
+				}), monthsValidator),
+			},
+			body:             "valid.json",
+			expectedResponse: []byte(`{"status":500,"kind":"Internal Error","code":"validation_error","details":"There was an error while validating the request","reference":"f00dcafe"}` + "\n"),
+			expectedStatus:   http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler := testAPI().Wrap(func(cmd *testCmd) {}, test.opts...)
+
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "https://example.com", mustOpen(t, test.body)))
+
+			if test.expectedStatus != rec.Code {
+				t.Errorf("expected status %d does not match received %d", test.expectedStatus, rec.Code)
+			}
+
+			if !bytes.Equal(test.expectedResponse, rec.Body.Bytes()) {
+				t.Errorf(
+					"response body does not match\nexpected: %s\nreceived: %s\n",
+					string(test.expectedResponse),
+					rec.Body.String(),
+				)
+			}
+		})
+	}
+}
+
+func TestWithValidator_API(t *testing.T) {
+	api := testAPI()
+	api.RequestValidator = &jsonapi.ValidatorChain{}
+
+	months := api.Wrap(func(cmd *testCmd) {}, jsonapi.WithValidator(monthsValidator))
+	plain := api.Wrap(func(cmd *testCmd) {})
+
+	// The validators of a handler are not added to the chain of the API
+	rec := httptest.NewRecorder()
+	plain.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "https://example.com", mustOpen(t, "valid.json")))
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected status %d does not match received %d: %s", http.StatusNoContent, rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	months.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "https://example.com", mustOpen(t, "valid.json")))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d does not match received %d", http.StatusBadRequest, rec.Code)
+	}
+}
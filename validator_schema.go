@@ -1,65 +1,81 @@
 package jsonapi
 
 import (
-	"bytes"
-	"github.com/xeipuuv/gojsonschema"
+	"encoding/json"
+	"fmt"
 	"io"
+	"math/big"
 	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/xeipuuv/gojsonreference"
+	"github.com/xeipuuv/gojsonschema"
 )
 
+// WithSchema validates the body of the requests against a json schema.
+//
+// The schema is compiled once, when the option is applied. It panics if the schema
+// cannot be read or compiled, so invalid schemas are caught at startup.
+//
+// The schema validator is added to the handler's ValidatorChain. See WithValidator.
 func WithSchema(schema io.Reader) OptsFn {
 	return func(h *JsonHandler) {
 		if schema == nil {
 			return
 		}
 
-		b, err := io.ReadAll(schema)
+		v, err := NewSchemaValidator(schema)
 		if err != nil {
 			panic(err)
 		}
 
-		h.RequestValidator = &jsonSchemaValidator{
-			loader: gojsonschema.NewBytesLoader(b),
-		}
+		WithValidator(v)(h)
 	}
 }
 
-// jsonSchemaValidator validates a request body using json testdata
-// It uses the "github.com/xeipuuv/gojsonschema" library to validate
-type jsonSchemaValidator struct {
-	loader gojsonschema.JSONLoader
-}
-
-func (v *jsonSchemaValidator) Validate(req *http.Request) ([]*ErrorItem, error) {
-	defer func(c io.Closer) {
-		_ = c.Close()
-	}(req.Body)
-
-	b, err := io.ReadAll(req.Body)
+// NewSchemaValidator compiles a json schema into a RequestValidator
+func NewSchemaValidator(schema io.Reader) (RequestValidator, error) {
+	b, err := io.ReadAll(schema)
 	if err != nil {
-		return nil, ErrValidation
+		return nil, fmt.Errorf("could not read json schema: %w", err)
 	}
 
-	loader := gojsonschema.NewBytesLoader(b)
+	s, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(b))
+	if err != nil {
+		return nil, fmt.Errorf("invalid json schema: %w", err)
+	}
 
-	buff := bytes.NewBuffer(b)
+	return &jsonSchemaValidator{schema: s}, nil
+}
 
-	// We change the body to the buffer
-	req.Body = io.NopCloser(buff)
+// jsonSchemaValidator validates a request body using a compiled json schema
+// It uses the "github.com/xeipuuv/gojsonschema" library to validate
+type jsonSchemaValidator struct {
+	schema *gojsonschema.Schema
+}
 
-	result, err := gojsonschema.Validate(v.loader, loader)
+func (v *jsonSchemaValidator) Validate(req *http.Request) ([]*ErrorItem, error) {
+	doc, err := decodeBody(req)
 	if err == io.EOF {
 		return nil, ErrEmptyBody
 	}
 
 	if err != nil {
 		return nil, &apiError{
-			code: 400,
-			msg:  "Error while validating the request",
-			prev: err,
+			code:    400,
+			msg:     "Error while validating the request",
+			errCode: "invalid_body",
+			kind:    KindInvalidRequest,
+			prev:    err,
 		}
 	}
 
+	result, err := v.schema.Validate(documentLoader{doc: doc})
+	if err != nil {
+		return nil, ErrValidation
+	}
+
 	if result.Valid() {
 		return nil, nil
 	}
@@ -67,12 +83,109 @@ func (v *jsonSchemaValidator) Validate(req *http.Request) ([]*ErrorItem, error)
 	errors := make([]*ErrorItem, 0, len(result.Errors()))
 
 	for _, res := range result.Errors() {
-		errors = append(errors, &ErrorItem{
-			Field: res.Field(),
-			Value: res.Value(),
-			Msg:   res.Description(),
-		})
+		errors = append(errors, schemaErrorItem(res))
 	}
 
+	// The order of the errors depends on the order in which the schema was parsed,
+	// which is random. We sort them so responses are consistent.
+	sort.SliceStable(errors, func(i, j int) bool {
+		return errors[i].Pointer < errors[j].Pointer
+	})
+
 	return errors, nil
 }
+
+// schemaErrorCodes maps the gojsonschema error types to our error codes.
+//
+// Error types that are not in the map are used as they are.
+var schemaErrorCodes = map[string]string{
+	"invalid_type":                    "type",
+	"string_gte":                      "min_length",
+	"string_lte":                      "max_length",
+	"number_gte":                      "minimum",
+	"number_gt":                       "exclusive_minimum",
+	"number_lte":                      "maximum",
+	"number_lt":                       "exclusive_maximum",
+	"array_min_items":                 "min_items",
+	"array_max_items":                 "max_items",
+	"array_min_properties":            "min_properties",
+	"array_max_properties":            "max_properties",
+	"array_no_additional_items":       "additional_items",
+	"additional_property_not_allowed": "additional_properties",
+	"unique":                          "unique_items",
+	"number_any_of":                   "any_of",
+	"number_one_of":                   "one_of",
+	"number_all_of":                   "all_of",
+	"number_not":                      "not",
+}
+
+// schemaErrorItem converts a gojsonschema error into an ErrorItem
+func schemaErrorItem(res gojsonschema.ResultError) *ErrorItem {
+	code, ok := schemaErrorCodes[res.Type()]
+	if !ok {
+		code = res.Type()
+	}
+
+	// The context is printed with a separator that cannot be in a key, so we can
+	// split it back into its tokens. The first one is always the root.
+	tokens := strings.Split(res.Context().String("\x00"), "\x00")[1:]
+
+	params := make(map[string]interface{}, len(res.Details()))
+	for k, v := range res.Details() {
+		switch k {
+		case "field", "context":
+			continue
+		}
+
+		if f, ok := v.(*big.Float); ok {
+			v = json.Number(f.Text('g', -1))
+		}
+
+		params[k] = v
+	}
+
+	// Required errors are reported on the object, but they are about the missing property
+	if code == "required" {
+		if p, ok := params["property"].(string); ok {
+			tokens = append(tokens, p)
+		}
+	}
+
+	if len(params) == 0 {
+		params = nil
+	}
+
+	return &ErrorItem{
+		Field:   res.Field(),
+		Pointer: JSONPointer(tokens...),
+		Source:  SourceBody,
+		Code:    code,
+		Params:  params,
+		Value:   res.Value(),
+		Msg:     res.Description(),
+	}
+}
+
+// documentLoader is a gojsonschema.JSONLoader over an already decoded document.
+//
+// The loaders shipped with gojsonschema decode (or even marshal and decode) their
+// source every time they are loaded, which we want to avoid.
+type documentLoader struct {
+	doc interface{}
+}
+
+func (l documentLoader) JsonSource() interface{} {
+	return l.doc
+}
+
+func (l documentLoader) LoadJSON() (interface{}, error) {
+	return l.doc, nil
+}
+
+func (l documentLoader) JsonReference() (gojsonreference.JsonReference, error) {
+	return gojsonreference.NewJsonReference("#")
+}
+
+func (l documentLoader) LoaderFactory() gojsonschema.JSONLoaderFactory {
+	return &gojsonschema.DefaultJSONLoaderFactory{}
+}
@@ -0,0 +1,175 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"sync"
+
+	"github.com/xeipuuv/gojsonreference"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// WithSchemaFS validates the body of the requests against the schema stored in path.
+//
+// Every json file in fsys is loaded, so the schema can reference definitions in other files,
+// either by a relative path or by their $id. It panics if any of the schemas is invalid.
+//
+// A new registry is created every time the option is used. Use NewSchemaRegistry and
+// SchemaRegistry.WithSchema when many handlers share the same file system.
+func WithSchemaFS(fsys fs.FS, path string) OptsFn {
+	r, err := NewSchemaRegistry(fsys)
+	if err != nil {
+		panic(err)
+	}
+
+	return r.WithSchema(path)
+}
+
+// A SchemaRegistry compiles the json schemas stored in a file system.
+//
+// References between schemas are resolved against the files in the registry, using
+// their path or their $id. Nothing is ever fetched from the network: a reference to a
+// schema that is not in the registry is an error.
+type SchemaRegistry struct {
+	mu      sync.Mutex
+	loader  *gojsonschema.SchemaLoader
+	schemas map[string]*gojsonschema.Schema
+}
+
+// NewSchemaRegistry loads every json file in fsys as a schema.
+//
+// All the schemas are compiled, so broken schemas or references are reported here, at
+// startup, and not when a request comes.
+func NewSchemaRegistry(fsys fs.FS) (*SchemaRegistry, error) {
+	r := &SchemaRegistry{
+		loader:  gojsonschema.NewSchemaLoader(),
+		schemas: make(map[string]*gojsonschema.Schema),
+	}
+
+	var paths []string
+
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || path.Ext(p) != ".json" {
+			return nil
+		}
+
+		b, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+
+		var doc interface{}
+		if err := dec.Decode(&doc); err != nil {
+			return fmt.Errorf("invalid json schema %s: %w", p, err)
+		}
+
+		if err := r.loader.AddSchema(schemaURL(p), documentLoader{doc: doc}); err != nil {
+			return fmt.Errorf("invalid json schema %s: %w", p, err)
+		}
+
+		paths = append(paths, p)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range paths {
+		if _, err := r.compile(p); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// Validator returns a RequestValidator for the schema stored in path
+func (r *SchemaRegistry) Validator(path string) (RequestValidator, error) {
+	s, err := r.compile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jsonSchemaValidator{schema: s}, nil
+}
+
+// WithSchema validates the body of the requests against the schema stored in path.
+//
+// It panics if the schema is not in the registry.
+func (r *SchemaRegistry) WithSchema(path string) OptsFn {
+	v, err := r.Validator(path)
+	if err != nil {
+		panic(err)
+	}
+
+	return WithValidator(v)
+}
+
+func (r *SchemaRegistry) compile(p string) (*gojsonschema.Schema, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p = path.Clean(p)
+
+	if s, ok := r.schemas[p]; ok {
+		return s, nil
+	}
+
+	s, err := r.loader.Compile(registryLoader{ref: schemaURL(p)})
+	if err != nil {
+		return nil, fmt.Errorf("invalid json schema %s: %w", p, err)
+	}
+
+	r.schemas[p] = s
+
+	return s, nil
+}
+
+// schemaURL is the url under which a schema is registered
+func schemaURL(p string) string {
+	u := url.URL{Scheme: "file", Path: "/" + path.Clean(p)}
+
+	return u.String()
+}
+
+// registryLoader references a schema in the registry.
+//
+// The schemas are all added to the registry in advance, so it is only asked to load
+// a schema when a reference points outside of the registry.
+type registryLoader struct {
+	ref string
+}
+
+func (l registryLoader) JsonSource() interface{} {
+	return l.ref
+}
+
+func (l registryLoader) LoadJSON() (interface{}, error) {
+	return nil, fmt.Errorf("schema %s is not in the registry", l.ref)
+}
+
+func (l registryLoader) JsonReference() (gojsonreference.JsonReference, error) {
+	return gojsonreference.NewJsonReference(l.ref)
+}
+
+func (l registryLoader) LoaderFactory() gojsonschema.JSONLoaderFactory {
+	return registryLoaderFactory{}
+}
+
+type registryLoaderFactory struct{}
+
+func (f registryLoaderFactory) New(source string) gojsonschema.JSONLoader {
+	return registryLoader{ref: source}
+}
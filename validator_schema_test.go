@@ -0,0 +1,257 @@
+package jsonapi_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mnavarrocarter/jsonapi"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+func TestNewSchemaValidator(t *testing.T) {
+	_, err := jsonapi.NewSchemaValidator(strings.NewReader(`{"type": "object", "required": "name"}`))
+	if err == nil {
+		t.Error("invalid schema should not compile")
+	}
+
+	_, err = jsonapi.NewSchemaValidator(mustOpen(t, "schema.json"))
+	if err != nil {
+		t.Errorf("valid schema should compile: %s", err)
+	}
+}
+
+func TestWithSchema_PanicsOnInvalidSchema(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("WithSchema should have panicked")
+		}
+	}()
+
+	jsonapi.Wrap(func() {}, jsonapi.WithSchema(strings.NewReader(`{"type": 12}`)))
+}
+
+func TestSchemaErrorItems(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"items": {
+				"type": "array",
+				"items": {
+					"type": "object",
+					"required": ["name", "price/unit"],
+					"properties": {
+						"name": {"type": "string", "minLength": 2},
+						"price/unit": {"type": "number", "minimum": 0.5}
+					}
+				}
+			}
+		}
+	}`
+
+	v, err := jsonapi.NewSchemaValidator(strings.NewReader(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "https://example.com", strings.NewReader(`{"items":[{"name":"a","price/unit":0.1},{"name":12}]}`))
+
+	items, err := v.Validate(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		pointer string
+		code    string
+		params  string
+	}{
+		{pointer: "/items/0/name", code: "min_length", params: `{"min":2}`},
+		{pointer: "/items/0/price~1unit", code: "minimum", params: `{"min":0.5}`},
+		{pointer: "/items/1/name", code: "type", params: `{"expected":"string","given":"integer"}`},
+		{pointer: "/items/1/price~1unit", code: "required", params: `{"property":"price/unit"}`},
+	}
+
+	if len(items) != len(expected) {
+		t.Fatalf("expected %d errors, received %d", len(expected), len(items))
+	}
+
+	for i, e := range expected {
+		params, _ := json.Marshal(items[i].Params)
+
+		if items[i].Pointer != e.pointer || items[i].Code != e.code || string(params) != e.params || items[i].Source != jsonapi.SourceBody {
+			t.Errorf(
+				"error #%d does not match\nexpected: %s %s %s\nreceived: %s %s %s",
+				i, e.pointer, e.code, e.params, items[i].Pointer, items[i].Code, params,
+			)
+		}
+	}
+}
+
+func TestWithSchemaFS(t *testing.T) {
+	schemas, err := fs.Sub(testdata, "testdata/schemas")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		name             string
+		body             string
+		expectedResponse []byte
+		expectedStatus   int
+	}{
+		{
+			name:             "valid body",
+			body:             `{"customer":"John","total":{"amount":100,"currency":"USD"},"shipping":{"street":"Main St","city":"Springfield"}}`,
+			expectedResponse: []byte(""),
+			expectedStatus:   http.StatusNoContent,
+		},
+		{
+			name:             "invalid body in referenced schemas",
+			body:             `{"customer":"John","total":{"amount":100,"currency":"GBP"},"shipping":{"street":"Main St","city":""}}`,
+			expectedResponse: []byte(`{"status":400,"kind":"Invalid Request","code":"validation_failed","details":"Validation errors","errors":[{"field":"shipping.city","pointer":"/shipping/city","source":"body","code":"min_length","params":{"min":1},"value":"","msg":"String length must be greater than or equal to 1"},{"field":"total.currency","pointer":"/total/currency","source":"body","code":"enum","params":{"allowed":"\"USD\", \"EUR\", \"CLP\""},"value":"GBP","msg":"total.currency must be one of the following: \"USD\", \"EUR\", \"CLP\""}]}` + "\n"),
+			expectedStatus:   http.StatusBadRequest,
+		},
+	}
+
+	handler := jsonapi.Wrap(func() {}, jsonapi.WithSchemaFS(schemas, "order.json"))
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "https://example.com", strings.NewReader(test.body)))
+
+			if test.expectedStatus != rec.Code {
+				t.Errorf("expected status %d does not match received %d", test.expectedStatus, rec.Code)
+			}
+
+			if !bytes.Equal(test.expectedResponse, rec.Body.Bytes()) {
+				t.Errorf(
+					"response body does not match\nexpected: %s\nreceived: %s\n",
+					string(test.expectedResponse),
+					rec.Body.String(),
+				)
+			}
+		})
+	}
+}
+
+func TestNewSchemaRegistry(t *testing.T) {
+	tt := []struct {
+		name string
+		fsys fs.FS
+	}{
+		{
+			name: "missing relative reference",
+			fsys: fstest.MapFS{
+				"user.json": {Data: []byte(`{"properties": {"address": {"$ref": "address.json"}}}`)},
+			},
+		},
+		{
+			name: "remote reference",
+			fsys: fstest.MapFS{
+				"user.json": {Data: []byte(`{"properties": {"address": {"$ref": "https://example.com/address.json"}}}`)},
+			},
+		},
+		{
+			name: "invalid json",
+			fsys: fstest.MapFS{
+				"user.json": {Data: []byte(`{"properties": `)},
+			},
+		},
+		{
+			name: "invalid schema",
+			fsys: fstest.MapFS{
+				"user.json":    {Data: []byte(`{"type": "object"}`)},
+				"address.json": {Data: []byte(`{"type": 12}`)},
+			},
+		},
+	}
+
+	for _, test := range tt {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := jsonapi.NewSchemaRegistry(test.fsys); err == nil {
+				t.Error("registry should not have been created")
+			}
+		})
+	}
+}
+
+// BenchmarkSchemaValidation compares validating against a schema compiled once
+// with recompiling the schema and reparsing the body on every request.
+func BenchmarkSchemaValidation(b *testing.B) {
+	schema := mustReadAll(b, "schema.json")
+	body := mustReadAll(b, "valid.json")
+
+	b.Run("compiled", func(b *testing.B) {
+		v, err := jsonapi.NewSchemaValidator(bytes.NewReader(schema))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			req := httptest.NewRequest(http.MethodPost, "https://example.com", bytes.NewReader(body))
+			if _, err := v.Validate(req); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("recompiled", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			req := httptest.NewRequest(http.MethodPost, "https://example.com", bytes.NewReader(body))
+			raw, err := io.ReadAll(req.Body)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			req.Body = io.NopCloser(bytes.NewBuffer(raw))
+
+			_, err = gojsonschema.Validate(gojsonschema.NewBytesLoader(schema), gojsonschema.NewBytesLoader(raw))
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkHandlerWithSchema(b *testing.B) {
+	body := mustReadAll(b, "valid.json")
+	handler := jsonapi.Wrap(func(cmd *testCmd) *testResp {
+		return &testResp{Msg: "success"}
+	}, jsonapi.WithSchema(bytes.NewReader(mustReadAll(b, "schema.json"))))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "https://example.com", bytes.NewReader(body)))
+
+		if rec.Code != http.StatusOK {
+			b.Fatalf("unexpected status %d", rec.Code)
+		}
+	}
+}
+
+func mustReadAll(tb testing.TB, name string) []byte {
+	tb.Helper()
+	b, err := testdata.ReadFile("testdata/" + name)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return b
+}
@@ -0,0 +1,102 @@
+package jsonapi
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// responseWriter wraps the http.ResponseWriter of a request to keep track of what has
+// been written to it.
+type responseWriter struct {
+	http.ResponseWriter
+	status int // The status code written, zero if none has been written yet
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	if rw, ok := w.(*responseWriter); ok {
+		return rw
+	}
+
+	return &responseWriter{ResponseWriter: w}
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher, if the wrapped writer does
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, so http.ResponseController can use it
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// statusCode returns the status code sent. Responses with no explicit status code are
+// sent with 200 by net/http.
+func (w *responseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
+
+// written tells whether the status code has already been sent
+func (w *responseWriter) written() bool {
+	return w.status != 0
+}
+
+// bufferedWriter holds the response until it is sent, so its headers can still be changed
+// once it has been encoded.
+//
+// The response is sent all at once, so flushing it does nothing: the responses of the
+// handlers that buffer them, with Server-Timing, ETags, Cache-Control or a cache, cannot
+// be streamed.
+type bufferedWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return w.buf.Write(b)
+}
+
+// Flush does nothing, as the response is sent all at once
+func (w *bufferedWriter) Flush() {}
+
+// send sends the buffered response
+func (w *bufferedWriter) send() {
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+
+	_, _ = w.buf.WriteTo(w.ResponseWriter)
+}